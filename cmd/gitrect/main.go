@@ -1,15 +1,18 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"path"
 	"path/filepath"
 	"strings"
+	"sync"
 
 	"github.com/karrick/godirwalk"
 )
@@ -40,6 +43,13 @@ func main() {
 	workDir := flag.String("d", "~/code", "Root directory to perform clones and updates")
 	flag.BoolVar(&verbose, "v", false, "verbose output")
 	flag.BoolVar(&debug, "debug", false, "debug-level output")
+	jobs := flag.Int("j", 1, "Number of repos to clone or update at once")
+	syncRepos := flag.Bool("sync", false, "Also fetch all remotes and fast-forward clean repos to their upstream")
+	status := flag.Bool("status", false, "Report each repo's branch, changes, stashes and unpushed work instead of rectifying")
+	tree := flag.Bool("tree", false, "With -status, report on the repos found under -d rather than those in the gitlist")
+	asJSON := flag.Bool("json", false, "With -status, print JSON rather than a table")
+	onlyDirty := flag.Bool("dirty", false, "With -status, show only repos with changes or untracked files")
+	onlyUnpushed := flag.Bool("unpushed", false, "With -status, show only repos with stashes or commits on no remote")
 	flag.Parse()
 
 	h, e := buildchdir(*workDir)
@@ -61,6 +71,25 @@ func main() {
 		os.Exit(1)
 	}
 
+	if *status {
+		var repos []Repo
+		if *tree {
+			repos, err = visit(h)
+		} else {
+			var gl Repolist
+			gl, err = loadConf(*confpath)
+			repos = gl.Repos
+		}
+		if err != nil {
+			fmt.Printf("failed to list repos: %v\n", err)
+			os.Exit(1)
+		}
+		if !reportStatus(gitpath, h, repos, *jobs, *asJSON, *onlyDirty, *onlyUnpushed) {
+			os.Exit(1)
+		}
+		return
+	}
+
 	if *update {
 		// Do updates
 		rlist, err := visit(h)
@@ -69,7 +98,7 @@ func main() {
 			os.Exit(1)
 		}
 		r := Repolist{Repos: rlist}
-		r.getRemotes(gitpath, h)
+		r.getRemotes(gitpath, h, *jobs)
 
 		if _, err := os.Stat(cpath); err == nil { // The file exists, but we want to overwrite it
 			err := os.Remove(cpath)
@@ -113,54 +142,100 @@ func main() {
 		}
 
 		// Walk the list of repos in gitlist
-		for i := range gl.Repos {
-			wd := filepath.Join(h, gl.Repos[i].Path)
-			if stat, err := os.Stat(wd); err != nil || !stat.IsDir() { // Repo not found
-				if verbose {
-					fmt.Printf("cloning repo: %s\n", gl.Repos[i].Path)
-				}
-				err := cloneRepo(gitpath, h, gl.Repos[i])
-				if err != nil {
-					fmt.Printf("failed to clone repository at path: %s :: %v\n", gl.Repos[i].Path, err)
-					continue
-				}
-				err = updateRemotes(gitpath, h, gl.Repos[i])
-				if err != nil {
-					fmt.Printf("failed to add remotes to new clone: %v\n", err)
-					continue
-				}
-			} else {
-				if verbose {
-					fmt.Printf("updating remotes for: %s\n", gl.Repos[i].Path)
-				}
-				err := updateRemotes(gitpath, h, gl.Repos[i])
-				if err != nil {
-					fmt.Printf("failed to update remotes: %v\n", err)
-					continue
-				}
+		results := make([]syncResult, len(gl.Repos))
+		runJobs(os.Stdout, *jobs, len(gl.Repos), func(i int, out io.Writer) {
+			results[i].path = gl.Repos[i].Path
+			if err := rectify(gitpath, h, gl.Repos[i], out); err != nil {
+				fmt.Fprintln(out, err)
+				results[i].fail(err)
+				return
+			}
+			if *syncRepos {
+				results[i] = syncRepo(gitpath, h, gl.Repos[i], out)
 			}
+		})
+		if *syncRepos && !printResults(results) {
+			os.Exit(1)
 		}
 	}
 }
 
+// rectify clones a repo that is missing and brings its remotes in line
+// with the gitlist, writing what it does to out.
+func rectify(gp, h string, r Repo, out io.Writer) error {
+	wd := filepath.Join(h, r.Path)
+	if stat, err := os.Stat(wd); err != nil || !stat.IsDir() { // Repo not found
+		if verbose {
+			fmt.Fprintf(out, "cloning repo: %s\n", r.Path)
+		}
+		err := cloneRepo(gp, h, r)
+		if err != nil {
+			return fmt.Errorf("failed to clone repository at path: %s :: %v", r.Path, err)
+		}
+		err = updateRemotes(gp, h, r, out)
+		if err != nil {
+			return fmt.Errorf("failed to add remotes to new clone %s: %v", r.Path, err)
+		}
+		return nil
+	}
+	if verbose {
+		fmt.Fprintf(out, "updating remotes for: %s\n", r.Path)
+	}
+	err := updateRemotes(gp, h, r, out)
+	if err != nil {
+		return fmt.Errorf("failed to update remotes for %s: %v", r.Path, err)
+	}
+	return nil
+}
+
+// runJobs calls job for each index below n on up to workers goroutines
+// at once. Each job writes to its own buffer, copied to w in one piece
+// when the job is done, so the output of different repos never
+// interleaves.
+func runJobs(w io.Writer, workers, n int, job func(i int, out io.Writer)) {
+	if workers < 1 {
+		workers = 1
+	}
+	next := make(chan int)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for g := 0; g < workers; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				var buf bytes.Buffer
+				job(i, &buf)
+				mu.Lock()
+				w.Write(buf.Bytes()) //nolint:errcheck
+				mu.Unlock()
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+}
+
+// git runs git with args in the directory dir, returning its combined output.
+func git(gp, dir string, args ...string) ([]byte, error) {
+	cmd := exec.Command(gp, args...)
+	cmd.Dir = dir
+	return cmd.CombinedOutput()
+}
+
 func cloneRepo(gp, h string, r Repo) error {
-	cmd := exec.Command(gp, "clone", r.Remotes["origin"], filepath.Join(h, r.Path))
-	_, cerr := cmd.CombinedOutput()
+	_, cerr := git(gp, h, "clone", r.Remotes["origin"], filepath.Join(h, r.Path))
 	return cerr
 }
 
-func updateRemotes(gp, h string, r Repo) error {
+func updateRemotes(gp, h string, r Repo, out io.Writer) error {
 	wd := filepath.Join(h, r.Path)
-	err := os.Chdir(wd)
-	if err != nil {
-		fmt.Printf("failed to chdir: %s : %v\n", wd, err)
-		return err
-	}
-	defer os.Chdir(h) //nolint:errcheck
 
 	// Gather current list of remotes to compare to map
-	cmd := exec.Command(gp, "remote")
-	res, cerr := cmd.CombinedOutput()
+	res, cerr := git(gp, wd, "remote")
 	if cerr != nil {
 		return cerr
 	}
@@ -171,10 +246,9 @@ func updateRemotes(gp, h string, r Repo) error {
 		if contains(local, k) {
 			continue
 		}
-		gadd := exec.Command(gp, "remote", "add", k, v)
-		_, err := gadd.CombinedOutput()
+		_, err := git(gp, wd, "remote", "add", k, v)
 		if err != nil {
-			fmt.Printf("failed to add remote %s=%s", k, v)
+			fmt.Fprintf(out, "failed to add remote %s=%s\n", k, v)
 			return err
 		}
 	}
@@ -184,27 +258,25 @@ func updateRemotes(gp, h string, r Repo) error {
 		if v == "" {
 			continue
 		}
-		gc := exec.Command(gp, "config", "--get", fmt.Sprintf("remote.%s.url", v))
-		resp, e := gc.CombinedOutput()
+		resp, e := git(gp, wd, "config", "--get", fmt.Sprintf("remote.%s.url", v))
 		if e != nil {
-			fmt.Printf("failed to get remote url: %s, %v\n", v, e)
+			fmt.Fprintf(out, "failed to get remote url: %s, %v\n", v, e)
 			continue
 		}
 		m, ok := r.Remotes[v]
 		if !ok {
 			if verbose {
-				fmt.Printf("new remote found: %s=%s @ %s\n", v, resp, wd)
+				fmt.Fprintf(out, "new remote found: %s=%s @ %s\n", v, resp, wd)
 			}
 			continue
 		}
 		if strings.TrimSpace(string(resp)) != m {
 			if verbose {
-				fmt.Printf("remote does not match: %s %s", wd, resp)
+				fmt.Fprintf(out, "remote does not match: %s %s", wd, resp)
 			}
-			gset := exec.Command(gp, "remote", "set-url", v, m)
-			_, err := gset.CombinedOutput()
+			_, err := git(gp, wd, "remote", "set-url", v, m)
 			if err != nil {
-				fmt.Printf("failed to set url: %s\n", m)
+				fmt.Fprintf(out, "failed to set url: %s\n", m)
 				return err
 			}
 
@@ -214,25 +286,20 @@ func updateRemotes(gp, h string, r Repo) error {
 	return nil
 }
 
-// getRemotes iterates through a repolist to add remotes to all identified repos
-func (a *Repolist) getRemotes(gp, h string) {
-	for i, r := range a.Repos {
+// getRemotes iterates through a repolist to add remotes to all identified repos,
+// using up to workers goroutines at once
+func (a *Repolist) getRemotes(gp, h string, workers int) {
+	runJobs(os.Stdout, workers, len(a.Repos), func(i int, out io.Writer) {
+		r := a.Repos[i]
 		a.Repos[i].Remotes = make(map[string]string, 3) // Prebuild map to avoid nil assignment
 
 		wd := filepath.Join(h, r.Path)
-		err := os.Chdir(wd)
-		if err != nil {
-			fmt.Printf("error: failed to chdir: %s : %v\n", wd, err)
-			os.Chdir(h) //nolint:errcheck
-			continue
-		}
 
 		// Gather current list of remotes to compare to map
-		cmd := exec.Command(gp, "remote")
-		re, cerr := cmd.CombinedOutput()
+		re, cerr := git(gp, wd, "remote")
 		if cerr != nil {
-			fmt.Printf("failed to gather remotes for: %s :: %v\n", r.Path, cerr)
-			break
+			fmt.Fprintf(out, "failed to gather remotes for: %s :: %v\n", r.Path, cerr)
+			return
 		}
 
 		local := strings.Split(string(re), "\n")
@@ -241,18 +308,16 @@ func (a *Repolist) getRemotes(gp, h string) {
 			if v == "" {
 				continue
 			}
-			gc := exec.Command(gp, "config", "--get", fmt.Sprintf("remote.%s.url", v))
-			resp, e := gc.CombinedOutput()
+			resp, e := git(gp, wd, "config", "--get", fmt.Sprintf("remote.%s.url", v))
 			if e != nil {
-				fmt.Printf("failed to get remote url: %s, %v\n", v, e)
+				fmt.Fprintf(out, "failed to get remote url: %s, %v\n", v, e)
 				continue
 			}
 			rem := strings.Split(string(resp), "\n")[0]
 			a.Repos[i].Remotes[v] = string(rem)
 
 		}
-		os.Chdir(h) //nolint:errcheck
-	}
+	})
 }
 
 // loadConf loads and parses the configuration file as passed in,
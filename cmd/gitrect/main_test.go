@@ -0,0 +1,45 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestRunJobs(t *testing.T) {
+	const jobs, lines = 50, 10
+	var runs [jobs]int32
+	var out bytes.Buffer
+	runJobs(&out, 4, jobs, func(i int, w io.Writer) {
+		atomic.AddInt32(&runs[i], 1)
+		for j := 0; j < lines; j++ {
+			fmt.Fprintf(w, "job %d line %d\n", i, j)
+			runtime.Gosched()
+		}
+	})
+
+	for i, n := range runs {
+		if n != 1 {
+			t.Errorf("job %d ran %d times, expected once", i, n)
+		}
+	}
+	got := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	if len(got) != jobs*lines {
+		t.Fatalf("expected %d lines, got %d", jobs*lines, len(got))
+	}
+	for start := 0; start < len(got); start += lines {
+		var i int
+		if _, err := fmt.Sscanf(got[start], "job %d line 0", &i); err != nil {
+			t.Fatalf("line %d: expected the start of a job, got %q", start+1, got[start])
+		}
+		for j := 0; j < lines; j++ {
+			if want := fmt.Sprintf("job %d line %d", i, j); got[start+j] != want {
+				t.Fatalf("line %d: expected %q, got %q", start+j+1, want, got[start+j])
+			}
+		}
+	}
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// RepoStatus describes the local state of a repository
+type RepoStatus struct {
+	Path      string         `json:"path"`
+	Branch    string         `json:"branch"` // HEAD when detached
+	Modified  int            `json:"modified"`
+	Untracked int            `json:"untracked"`
+	Stashes   int            `json:"stashes"`
+	Branches  []BranchStatus `json:"branches"`
+	Error     string         `json:"error,omitempty"`
+}
+
+// BranchStatus describes a local branch against its upstream and the remotes
+type BranchStatus struct {
+	Name     string `json:"name"`
+	Upstream string `json:"upstream,omitempty"`
+	Ahead    int    `json:"ahead"`
+	Behind   int    `json:"behind"`
+	Unpushed int    `json:"unpushed"` // commits found on no remote at all
+}
+
+// dirty reports whether the working tree has changes or untracked files
+func (s *RepoStatus) dirty() bool {
+	return s.Modified > 0 || s.Untracked > 0
+}
+
+// unpushed reports whether the repo holds work that exists nowhere else:
+// commits on no remote, or stashed changes
+func (s *RepoStatus) unpushed() bool {
+	for _, b := range s.Branches {
+		if b.Unpushed > 0 {
+			return true
+		}
+	}
+	return s.Stashes > 0
+}
+
+// current returns the status of the checked out branch, if any
+func (s *RepoStatus) current() *BranchStatus {
+	for i := range s.Branches {
+		if s.Branches[i].Name == s.Branch {
+			return &s.Branches[i]
+		}
+	}
+	return nil
+}
+
+// repoStatus gathers the status of the repo at path under h
+func repoStatus(gp, h, path string) RepoStatus {
+	s := RepoStatus{Path: path}
+	wd := filepath.Join(h, path)
+	fail := func(err error, output []byte) RepoStatus {
+		s.Error = gitError(err, output).Error()
+		return s
+	}
+
+	s.Branch = "HEAD"
+	if resp, err := git(gp, wd, "symbolic-ref", "--short", "-q", "HEAD"); err == nil {
+		s.Branch = strings.TrimSpace(string(resp))
+	}
+
+	resp, err := git(gp, wd, "status", "--porcelain")
+	if err != nil {
+		return fail(err, resp)
+	}
+	for _, line := range strings.Split(string(resp), "\n") {
+		switch {
+		case strings.HasPrefix(line, "??"):
+			s.Untracked++
+		case line != "":
+			s.Modified++
+		}
+	}
+
+	resp, err = git(gp, wd, "stash", "list")
+	if err != nil {
+		return fail(err, resp)
+	}
+	for _, line := range strings.Split(string(resp), "\n") {
+		if line != "" {
+			s.Stashes++
+		}
+	}
+
+	// Full ref names keep branches apart from files of the same name
+	resp, err = git(gp, wd, "for-each-ref", "--format=%(refname:short)\t%(refname)\t%(upstream:short)\t%(upstream)", "refs/heads")
+	if err != nil {
+		return fail(err, resp)
+	}
+	for _, line := range strings.Split(strings.TrimSpace(string(resp)), "\n") {
+		if line == "" {
+			continue
+		}
+		f := strings.Split(line, "\t")
+		if len(f) != 4 {
+			continue
+		}
+		b := BranchStatus{Name: f[0]}
+		ref := f[1]
+		if f[3] != "" {
+			b.Upstream = f[2]
+			// An upstream that is gone leaves the counts at zero
+			if resp, err := git(gp, wd, "rev-list", "--left-right", "--count", ref+"..."+f[3], "--"); err == nil {
+				if counts := strings.Fields(string(resp)); len(counts) == 2 {
+					b.Ahead, _ = strconv.Atoi(counts[0])
+					b.Behind, _ = strconv.Atoi(counts[1])
+				}
+			}
+		}
+		resp, err := git(gp, wd, "rev-list", "--count", ref, "--not", "--remotes", "--")
+		if err != nil {
+			return fail(err, resp)
+		}
+		b.Unpushed, _ = strconv.Atoi(strings.TrimSpace(string(resp)))
+		s.Branches = append(s.Branches, b)
+	}
+
+	// Commits made on a detached HEAD belong to no branch
+	if s.Branch == "HEAD" {
+		resp, err := git(gp, wd, "rev-list", "--count", "HEAD", "--not", "--branches", "--remotes", "--")
+		if err != nil {
+			return fail(err, resp)
+		}
+		if n, _ := strconv.Atoi(strings.TrimSpace(string(resp))); n > 0 {
+			s.Branches = append(s.Branches, BranchStatus{Name: "HEAD", Unpushed: n})
+		}
+	}
+	return s
+}
+
+// printStatusTable writes one line per repo: its branch and how it
+// stands against the upstream, local changes, stashes and unpushed branches
+func printStatusTable(w io.Writer, statuses []RepoStatus) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "REPO\tBRANCH\tUPSTREAM\tCHANGES\tSTASHES\tUNPUSHED")
+	for _, s := range statuses {
+		if s.Error != "" {
+			fmt.Fprintf(tw, "%s\t-\t-\t-\t-\terror: %s\n", s.Path, s.Error)
+			continue
+		}
+
+		upstream := "-"
+		if b := s.current(); b != nil && b.Upstream != "" {
+			upstream = fmt.Sprintf("+%d -%d", b.Ahead, b.Behind)
+		}
+		changes := "clean"
+		if s.dirty() {
+			changes = fmt.Sprintf("%d modified, %d untracked", s.Modified, s.Untracked)
+		}
+		var unpushed []string
+		for _, b := range s.Branches {
+			if b.Unpushed > 0 {
+				unpushed = append(unpushed, fmt.Sprintf("%s(%d)", b.Name, b.Unpushed))
+			}
+		}
+		if len(unpushed) == 0 {
+			unpushed = []string{"-"}
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%d\t%s\n",
+			s.Path, s.Branch, upstream, changes, s.Stashes, strings.Join(unpushed, " "))
+	}
+	return tw.Flush()
+}
+
+// reportStatus gathers the status of repos on up to workers goroutines
+// and prints those passing the filters as a table or as JSON. With both
+// filters set, a repo passing either is shown. It reports whether every
+// status could be read.
+func reportStatus(gp, h string, repos []Repo, workers int, asJSON, onlyDirty, onlyUnpushed bool) bool {
+	statuses := make([]RepoStatus, len(repos))
+	runJobs(os.Stdout, workers, len(repos), func(i int, out io.Writer) {
+		statuses[i] = repoStatus(gp, h, repos[i].Path)
+	})
+
+	ok := true
+	shown := make([]RepoStatus, 0, len(statuses))
+	for _, s := range statuses {
+		if s.Error != "" {
+			ok = false
+		} else if (onlyDirty || onlyUnpushed) && !(onlyDirty && s.dirty()) && !(onlyUnpushed && s.unpushed()) {
+			continue
+		}
+		shown = append(shown, s)
+	}
+
+	if asJSON {
+		b, err := json.MarshalIndent(shown, "", "  ")
+		if err != nil {
+			fmt.Printf("json encoding error: %v\n", err)
+			return false
+		}
+		fmt.Println(string(b))
+		return ok
+	}
+	if err := printStatusTable(os.Stdout, shown); err != nil {
+		fmt.Printf("failed to write status: %v\n", err)
+		return false
+	}
+	return ok
+}
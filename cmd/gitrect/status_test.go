@@ -0,0 +1,138 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// run runs git in dir for a test, failing it on error
+func run(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(),
+		"GIT_AUTHOR_NAME=test", "GIT_AUTHOR_EMAIL=test@example.com",
+		"GIT_COMMITTER_NAME=test", "GIT_COMMITTER_EMAIL=test@example.com")
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, out)
+	}
+	return string(out)
+}
+
+// commit writes a file in the repo at dir and commits it
+func commit(t *testing.T, dir, name, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	run(t, dir, "add", name)
+	run(t, dir, "commit", "-q", "-m", name)
+}
+
+// testRemote makes a bare origin holding one commit on main, and a
+// clone of it at seed to push further commits from. It returns the
+// git binary and the directory holding both.
+func testRemote(t *testing.T) (gp, h string) {
+	t.Helper()
+	gp, err := exec.LookPath("git")
+	if err != nil {
+		t.Skip("git not found")
+	}
+	h, err = ioutil.TempDir("", "gitrect")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(h) }) //nolint:errcheck
+
+	run(t, h, "init", "-q", "--bare", "origin.git")
+	run(t, h, "--git-dir=origin.git", "symbolic-ref", "HEAD", "refs/heads/main")
+	run(t, h, "clone", "-q", "origin.git", "seed")
+	run(t, filepath.Join(h, "seed"), "checkout", "-q", "-b", "main")
+	commit(t, filepath.Join(h, "seed"), "README", "one\n")
+	run(t, filepath.Join(h, "seed"), "push", "-q", "origin", "main")
+	return gp, h
+}
+
+// clone clones the test origin to name under h
+func clone(t *testing.T, h, name string) string {
+	t.Helper()
+	run(t, h, "clone", "-q", "origin.git", name)
+	return filepath.Join(h, name)
+}
+
+func TestRepoStatus(t *testing.T) {
+	gp, h := testRemote(t)
+	wd := clone(t, h, "work")
+
+	// A stash, a local commit on main and a local-only branch named
+	// like a directory in the work tree
+	commit(t, wd, "stashed", "a\n")
+	if err := ioutil.WriteFile(filepath.Join(wd, "stashed"), []byte("b\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	run(t, wd, "stash", "-q")
+	commit(t, wd, "docs/index.md", "docs\n")
+	run(t, wd, "branch", "docs")
+	run(t, wd, "checkout", "-q", "docs")
+	commit(t, wd, "docs/more.md", "more\n")
+	run(t, wd, "checkout", "-q", "main")
+
+	// Changes and untracked files in the work tree
+	if err := ioutil.WriteFile(filepath.Join(wd, "README"), []byte("changed\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(wd, "new"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	s := repoStatus(gp, h, "work")
+	if s.Error != "" {
+		t.Fatalf("unexpected error: %s", s.Error)
+	}
+	if s.Branch != "main" || s.Modified != 1 || s.Untracked != 1 || s.Stashes != 1 {
+		t.Errorf("expected main with 1 modified, 1 untracked and 1 stash, got %+v", s)
+	}
+	want := []BranchStatus{
+		{Name: "docs", Unpushed: 3},
+		{Name: "main", Upstream: "origin/main", Ahead: 2, Unpushed: 2},
+	}
+	if len(s.Branches) != len(want) {
+		t.Fatalf("expected branches %+v, got %+v", want, s.Branches)
+	}
+	for i := range want {
+		if s.Branches[i] != want[i] {
+			t.Errorf("expected %+v, got %+v", want[i], s.Branches[i])
+		}
+	}
+	if !s.dirty() || !s.unpushed() {
+		t.Errorf("expected dirty and unpushed, got %+v", s)
+	}
+
+	// A clean clone has nothing to report
+	clone(t, h, "clean")
+	c := repoStatus(gp, h, "clean")
+	if c.Error != "" || c.dirty() || c.unpushed() {
+		t.Errorf("expected a clean clone, got %+v", c)
+	}
+}
+
+func TestGitError(t *testing.T) {
+	out := []byte("fatal: ambiguous argument 'docs': both revision and filename\n" +
+		"Use '--' to separate paths from revisions, like this:\n" +
+		"'git <command> [<revision>...] -- [<file>...]'\n")
+	err := gitError(exec.ErrNotFound, out)
+	if !strings.Contains(err.Error(), "fatal: ambiguous argument") {
+		t.Errorf("expected the fatal line, got %v", err)
+	}
+	if err := gitError(exec.ErrNotFound, []byte("something went wrong\n")); !strings.Contains(err.Error(), "something went wrong") {
+		t.Errorf("expected the last line, got %v", err)
+	}
+}
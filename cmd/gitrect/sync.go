@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// Outcomes of syncing a repo
+const (
+	syncUpdated = "updated"
+	syncCurrent = "current"
+	syncSkipped = "skipped"
+	syncFailed  = "failed"
+)
+
+// syncResult records what syncing one repo did
+type syncResult struct {
+	path    string
+	outcome string
+	detail  string
+}
+
+func (s *syncResult) fail(err error) {
+	s.outcome, s.detail = syncFailed, err.Error()
+}
+
+// syncRepo fetches every remote of a repo, then fast-forwards its
+// current branch to the upstream when the working tree is clean. It
+// never merges or rebases: a dirty tree, a detached head, a branch
+// without upstream and one that has diverged are all left alone.
+func syncRepo(gp, h string, r Repo, out io.Writer) syncResult {
+	res := syncResult{path: r.Path}
+	wd := filepath.Join(h, r.Path)
+	if verbose {
+		fmt.Fprintf(out, "fetching: %s\n", r.Path)
+	}
+
+	if resp, err := git(gp, wd, "fetch", "--all", "--quiet"); err != nil {
+		res.fail(gitError(err, resp))
+		return res
+	}
+
+	resp, err := git(gp, wd, "status", "--porcelain", "--untracked-files=no")
+	if err != nil {
+		res.fail(gitError(err, resp))
+		return res
+	}
+	if len(strings.TrimSpace(string(resp))) > 0 {
+		res.outcome, res.detail = syncSkipped, "dirty working tree"
+		return res
+	}
+
+	if _, err := git(gp, wd, "symbolic-ref", "-q", "HEAD"); err != nil {
+		res.outcome, res.detail = syncSkipped, "detached HEAD"
+		return res
+	}
+	upstream, err := git(gp, wd, "rev-parse", "--abbrev-ref", "--symbolic-full-name", "@{u}")
+	if err != nil {
+		res.outcome, res.detail = syncSkipped, "no upstream"
+		return res
+	}
+
+	resp, err = git(gp, wd, "rev-list", "--left-right", "--count", "HEAD...@{u}", "--")
+	if err != nil {
+		res.fail(gitError(err, resp))
+		return res
+	}
+	counts := strings.Fields(string(resp))
+	if len(counts) != 2 {
+		res.fail(fmt.Errorf("unexpected rev-list output: %q", resp))
+		return res
+	}
+	ahead, _ := strconv.Atoi(counts[0])
+	behind, _ := strconv.Atoi(counts[1])
+	switch {
+	case behind == 0:
+		res.outcome = syncCurrent
+		if ahead > 0 {
+			res.detail = fmt.Sprintf("%d ahead of %s", ahead, strings.TrimSpace(string(upstream)))
+		}
+		return res
+	case ahead > 0:
+		res.outcome = syncSkipped
+		res.detail = fmt.Sprintf("diverged from %s: %d ahead, %d behind", strings.TrimSpace(string(upstream)), ahead, behind)
+		return res
+	}
+
+	if resp, err := git(gp, wd, "merge", "--ff-only", "--quiet", "@{u}"); err != nil {
+		res.fail(gitError(err, resp))
+		return res
+	}
+	res.outcome = syncUpdated
+	res.detail = fmt.Sprintf("fast-forwarded %d from %s", behind, strings.TrimSpace(string(upstream)))
+	if verbose {
+		fmt.Fprintf(out, "updated %s: %s\n", r.Path, res.detail)
+	}
+	return res
+}
+
+// gitError adds what git printed to the error of a failed command: its
+// first fatal or error line, or else the last line of its output, as
+// usage hints follow the message that explains them
+func gitError(err error, output []byte) error {
+	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+	msg := lines[len(lines)-1]
+	for _, line := range lines {
+		if strings.HasPrefix(line, "fatal:") || strings.HasPrefix(line, "error:") {
+			msg = line
+			break
+		}
+	}
+	if msg != "" {
+		return fmt.Errorf("%v: %s", err, msg)
+	}
+	return err
+}
+
+// printResults prints a table of the repos that were updated, skipped or
+// failed, followed by a count of each outcome. It reports whether no repo
+// failed.
+func printResults(results []syncResult) bool {
+	counts := map[string]int{}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "REPO\tRESULT\tDETAIL")
+	for _, r := range results {
+		counts[r.outcome]++
+		if r.outcome == syncCurrent && r.detail == "" {
+			continue
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", r.path, r.outcome, r.detail)
+	}
+	tw.Flush() //nolint:errcheck
+	fmt.Printf("%d updated, %d current, %d skipped, %d failed\n",
+		counts[syncUpdated], counts[syncCurrent], counts[syncSkipped], counts[syncFailed])
+	return counts[syncFailed] == 0
+}
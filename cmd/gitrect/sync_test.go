@@ -0,0 +1,70 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSyncRepo(t *testing.T) {
+	gp, h := testRemote(t)
+	tests := []struct {
+		name    string
+		prepare func(t *testing.T, wd string)
+		outcome string
+		detail  string
+	}{
+		{"behind", func(t *testing.T, wd string) {}, syncUpdated, "fast-forwarded 1 from origin/main"},
+		{"dirty", func(t *testing.T, wd string) {
+			if err := ioutil.WriteFile(filepath.Join(wd, "README"), []byte("local\n"), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}, syncSkipped, "dirty working tree"},
+		{"diverged", func(t *testing.T, wd string) {
+			commit(t, wd, "local", "local\n")
+		}, syncSkipped, "diverged from origin/main: 1 ahead, 1 behind"},
+		{"ahead", func(t *testing.T, wd string) {
+			run(t, wd, "pull", "-q", "--ff-only")
+			commit(t, wd, "local", "local\n")
+		}, syncCurrent, "1 ahead of origin/main"},
+		{"detached", func(t *testing.T, wd string) {
+			run(t, wd, "checkout", "-q", "--detach")
+		}, syncSkipped, "detached HEAD"},
+		{"no-upstream", func(t *testing.T, wd string) {
+			run(t, wd, "checkout", "-q", "-b", "topic")
+		}, syncSkipped, "no upstream"},
+	}
+
+	// Clone every repo, then move the origin on so that each is behind
+	for _, test := range tests {
+		clone(t, h, test.name)
+	}
+	commit(t, filepath.Join(h, "seed"), "NEWS", "two\n")
+	run(t, filepath.Join(h, "seed"), "push", "-q", "origin", "main")
+
+	for _, test := range tests {
+		wd := filepath.Join(h, test.name)
+		before := strings.TrimSpace(run(t, wd, "rev-parse", "HEAD"))
+		test.prepare(t, wd)
+		prepared := strings.TrimSpace(run(t, wd, "rev-parse", "HEAD"))
+
+		res := syncRepo(gp, h, Repo{Path: test.name}, ioutil.Discard)
+		if res.outcome != test.outcome || res.detail != test.detail {
+			t.Errorf("%s: expected %s %q, got %s %q", test.name, test.outcome, test.detail, res.outcome, res.detail)
+		}
+
+		after := strings.TrimSpace(run(t, wd, "rev-parse", "HEAD"))
+		origin := strings.TrimSpace(run(t, wd, "rev-parse", "origin/main"))
+		switch {
+		case test.outcome == syncUpdated && after != origin:
+			t.Errorf("%s: expected HEAD at origin/main %s, got %s", test.name, origin, after)
+		case test.outcome != syncUpdated && after != prepared:
+			t.Errorf("%s: expected HEAD left at %s, got %s (cloned at %s)", test.name, prepared, after, before)
+		}
+	}
+
+	if res := syncRepo(gp, h, Repo{Path: "missing"}, ioutil.Discard); res.outcome != syncFailed {
+		t.Errorf("expected a missing repo to fail, got %+v", res)
+	}
+}
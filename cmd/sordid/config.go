@@ -0,0 +1,165 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// configName is the file sordid looks for in a file's directory and
+// each of its parents.
+const configName = ".sordid"
+
+// config maps file patterns onto sort rules. It is read from JSON:
+//
+//	{"rules": [
+//		{"files": ["allowlists/*.txt"], "flags": {"u": true}},
+//		{"files": ["**/hosts.csv"], "flags": {"format": "csv", "by": ["host", "port:n"]}},
+//		{"files": ["*.go"], "flags": {"preset": "goimports"}}
+//	]}
+//
+// Patterns are relative to the config file's directory; one without a
+// slash matches the base name at any depth. The first rule to match a
+// file applies.
+type config struct {
+	dir   string
+	Rules []rule `json:"rules"`
+}
+
+// rule sets flags for the files matching any of its patterns. Flag
+// names are those of the command line, without the dash; flags that
+// may be repeated take a list.
+type rule struct {
+	Files []string               `json:"files"`
+	Flags map[string]interface{} `json:"flags"`
+}
+
+// loadedConfigs caches config files by absolute name, as every file
+// of a recursive run looks for its own.
+var loadedConfigs = map[string]*config{}
+
+// loadConfig reads a config file.
+func loadConfig(name string) (*config, error) {
+	abs, err := filepath.Abs(name)
+	if err != nil {
+		return nil, err
+	}
+	if c, ok := loadedConfigs[abs]; ok {
+		return c, nil
+	}
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	c := &config{dir: filepath.Dir(abs)}
+	if err := json.Unmarshal(data, c); err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	loadedConfigs[abs] = c
+	return c, nil
+}
+
+// findConfig returns the config named by explicit, or else the nearest
+// one in the directory of target or above it. It returns nil when
+// there is none.
+func findConfig(explicit, target string) (*config, error) {
+	if explicit != "" {
+		return loadConfig(explicit)
+	}
+	abs, err := filepath.Abs(target)
+	if err != nil {
+		return nil, err
+	}
+	for dir := filepath.Dir(abs); ; dir = filepath.Dir(dir) {
+		name := filepath.Join(dir, configName)
+		if _, err := os.Stat(name); err == nil {
+			return loadConfig(name)
+		}
+		if dir == filepath.Dir(dir) {
+			return nil, nil
+		}
+	}
+}
+
+// findRule returns the rule that applies to target, or nil.
+func findRule(explicit, target string) (*rule, error) {
+	c, err := findConfig(explicit, target)
+	if err != nil || c == nil {
+		return nil, err
+	}
+	return c.match(target)
+}
+
+// match returns the first rule with a pattern matching target, or nil.
+func (c *config) match(target string) (*rule, error) {
+	abs, err := filepath.Abs(target)
+	if err != nil {
+		return nil, err
+	}
+	rel, err := filepath.Rel(c.dir, abs)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		// Files outside the config's tree only match base name patterns.
+		rel = filepath.Base(abs)
+	}
+	rel = filepath.ToSlash(rel)
+
+	for i := range c.Rules {
+		ok, err := matchAny(c.Rules[i].Files, rel)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			return &c.Rules[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// apply sets the rule's flags on fs, leaving alone those already set
+// there.
+func (r *rule) apply(fs *flag.FlagSet) error {
+	set := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+
+	names := make([]string, 0, len(r.Flags))
+	for name := range r.Flags {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if runFlags[name] || fs.Lookup(name) == nil {
+			return fmt.Errorf("flag -%s cannot be set by a rule", name)
+		}
+		if set[name] {
+			continue
+		}
+		values, ok := r.Flags[name].([]interface{})
+		if !ok {
+			values = []interface{}{r.Flags[name]}
+		}
+		for _, v := range values {
+			var s string
+			switch t := v.(type) {
+			case string:
+				s = t
+			case bool:
+				s = strconv.FormatBool(t)
+			case float64:
+				s = strconv.FormatFloat(t, 'f', -1, 64)
+			default:
+				return fmt.Errorf("flag -%s: unsupported value %v", name, v)
+			}
+			if err := fs.Set(name, s); err != nil {
+				return fmt.Errorf("flag -%s: %v", name, err)
+			}
+		}
+	}
+	return nil
+}
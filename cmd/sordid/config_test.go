@@ -0,0 +1,93 @@
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMatchGlob(t *testing.T) {
+	tests := []struct {
+		pattern, name string
+		want          bool
+	}{
+		{"*.txt", "a.txt", true},
+		{"*.txt", "dir/a.txt", false},
+		{"dir/*.txt", "dir/a.txt", true},
+		{"**/a.txt", "a.txt", true},
+		{"**/a.txt", "x/y/a.txt", true},
+		{"x/**", "x/y/z", true},
+		{"x/**/z", "x/z", true},
+		{"x/**/z", "y/z", false},
+	}
+	for _, test := range tests {
+		got, err := matchGlob(test.pattern, test.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != test.want {
+			t.Errorf("matchGlob(%q, %q) = %v, expected %v", test.pattern, test.name, got, test.want)
+		}
+	}
+}
+
+func TestConfigRules(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sordid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	conf := `{"rules": [
+		{"files": ["lists/*.txt"], "flags": {"k": ["2,2n"], "u": true}},
+		{"files": ["*.txt"], "flags": {"r": true}}
+	]}`
+	if err := ioutil.WriteFile(filepath.Join(dir, configName), []byte(conf), 0640); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "lists", "deep"), 0750); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		file string
+		args []string
+		want []string
+	}{
+		{"lists/a.txt", nil, []string{"x 2", "x 10"}},
+		{"lists/a.txt", []string{"-k", "2,2"}, []string{"x 10", "x 2"}},
+		{"lists/deep/a.txt", nil, []string{"x 2", "x 10", "x 10"}},
+		{"lists/deep/a.txt", []string{"-r=false"}, []string{"x 10", "x 10", "x 2"}},
+		{"a.csv", nil, []string{"x 10", "x 10", "x 2"}},
+	}
+	for _, test := range tests {
+		fs := flag.NewFlagSet("sordid", flag.ContinueOnError)
+		s := defineFlags(fs)
+		if err := fs.Parse(test.args); err != nil {
+			t.Fatal(err)
+		}
+		sorter, err := s.sorterFor(filepath.Join(dir, test.file), test.args)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := sorter.SortLines([]string{"x 10", "x 2", "x 10"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Join(got, "|") != strings.Join(test.want, "|") {
+			t.Errorf("%s %q: expected %q, got %q", test.file, test.args, test.want, got)
+		}
+	}
+}
+
+func TestConfigRejectsRunFlags(t *testing.T) {
+	r := &rule{Flags: map[string]interface{}{"o": "out"}}
+	fs := flag.NewFlagSet("sordid", flag.ContinueOnError)
+	defineFlags(fs)
+	if err := r.apply(fs); err == nil {
+		t.Error("expected an error for -o in a rule")
+	}
+}
@@ -1,69 +1,467 @@
 package main
 
 import (
-	"bufio"
+	"errors"
 	"flag"
 	"fmt"
+	"io/ioutil"
 	"log"
 	"os"
-	"sort"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/tydavis/utilities/pkg/sordid"
 )
 
-// readLines reads a whole file into memory
-// and returns a slice of its lines.
-func readLines(path string) ([]string, error) {
-	file, err := os.Open(path)
+// settings holds the values of the command-line flags.
+type settings struct {
+	file, output    string
+	merge           bool
+	check, showDiff bool
+	backup          string
+	undo            bool
+	config          string
+	noConfig        bool
+	recursive       bool
+	include         stringList
+	exclude         stringList
+	gitignore       bool
+	dryRun          bool
+	memory, tmpDir  string
+	nul             bool
+	parallel        int
+	keys            stringList
+	sep             string
+	reverse         bool
+	skipBlanks      bool
+	foldCase        bool
+	foldAccents     bool
+	dictionary      bool
+	printable       bool
+	locale, mode    string
+	numeric         bool
+	timeLayout      string
+	timeZone        string
+	badTime         string
+	regions         bool
+	marker, comment string
+	groups, contin  bool
+	unique          bool
+	keep            string
+	count           bool
+	dups, singles   bool
+	collapse        bool
+	top, bottom     int
+	shuffle         bool
+	sample          int
+	seed            int64
+	preset, local   string
+	recFormat       string
+	fields          stringList
+	arrays          stringList
+	header          bool
+	paragraphs      bool
+	recordSep       string
+	recordStart     string
+	recordKey       string
+}
+
+// stringList collects the values of a repeated flag.
+type stringList []string
+
+func (l *stringList) String() string { return strings.Join(*l, " ") }
+
+func (l *stringList) Set(s string) error {
+	*l = append(*l, s)
+	return nil
+}
+
+// runFlags are the flags that choose what sordid does rather than
+// how it sorts, which a config file may not set.
+var runFlags = map[string]bool{
+	"f": true, "o": true, "merge": true, "check": true, "diff": true, "config": true, "noconfig": true,
+	"backup": true, "undo": true,
+	"R": true, "include": true, "exclude": true, "gitignore": true, "dry-run": true,
+}
+
+// defineFlags declares sordid's flags on fs.
+func defineFlags(fs *flag.FlagSet) *settings {
+	s := &settings{}
+	fs.StringVar(&s.file, "f", "", "File to sort, more may follow as arguments; - or none reads stdin")
+	fs.StringVar(&s.output, "o", "", "Write all inputs sorted together to this file, - for stdout, instead of sorting each in place")
+	fs.BoolVar(&s.merge, "merge", false, "Merge inputs that are each sorted already, without sorting again")
+	fs.BoolVar(&s.check, "check", false, "Only check that files are sorted; exit 1 if any is not")
+	fs.BoolVar(&s.showDiff, "diff", false, "Print a unified diff of each file sorting changes, or with -check would change")
+	fs.StringVar(&s.backup, "backup", "", "Keep each file sorted in place under its name plus this `suffix`, e.g. .bak")
+	fs.BoolVar(&s.undo, "undo", false, "Restore the files given from their -backup copies instead of sorting")
+	fs.StringVar(&s.config, "config", "", "Sort rules file, default: the nearest "+configName+" above each file")
+	fs.BoolVar(&s.noConfig, "noconfig", false, "Ignore "+configName+" files")
+	fs.BoolVar(&s.recursive, "R", false, "Sort the files under directories given as arguments, and summarize what changed")
+	fs.Var(&s.include, "include", "With -R, sort only files matching this `glob`; ** matches any directories; may be repeated")
+	fs.Var(&s.exclude, "exclude", "With -R, skip files and directories matching this `glob`; may be repeated")
+	fs.BoolVar(&s.gitignore, "gitignore", true, "With -R, skip what .gitignore files ignore")
+	fs.BoolVar(&s.dryRun, "dry-run", false, "Print a diff of each file sorting would change, without changing it")
+
+	fs.StringVar(&s.memory, "S", "", "Memory ceiling for sorting, e.g. 512M; larger files spill sorted runs to disk")
+	fs.StringVar(&s.tmpDir, "T", "", "Directory for temporary runs, default: system temp dir")
+	fs.IntVar(&s.parallel, "parallel", runtime.GOMAXPROCS(0), "Number of goroutines sorting at once, 1 to sort on one core")
+	fs.BoolVar(&s.nul, "z", false, "Records end in NUL rather than newline, as from find -print0")
+
+	fs.Var(&s.keys, "k", "Sort key `F[.C][OPTS][,F[.C][OPTS]]` as in sort(1), may be repeated")
+	fs.StringVar(&s.sep, "t", "", "Field separator, default: runs of blanks")
+	fs.BoolVar(&s.reverse, "r", false, "Reverse the sort order")
+	fs.BoolVar(&s.skipBlanks, "b", false, "Ignore leading blanks in keys")
+	fs.BoolVar(&s.foldCase, "fold", false, "Ignore case")
+	fs.BoolVar(&s.foldAccents, "A", false, "Ignore accents and other diacritics")
+	fs.BoolVar(&s.dictionary, "d", false, "Dictionary order: consider only blanks, letters and digits")
+	fs.BoolVar(&s.printable, "i", false, "Consider only printable characters")
+	fs.StringVar(&s.locale, "locale", "", "Collate by the Unicode Collation Algorithm for this language, e.g. de or sv; implies -mode collate")
+	fs.BoolVar(&s.numeric, "n", false, "Compare keys numerically, same as -mode numeric")
+	fs.StringVar(&s.mode, "mode", "lexical", "Comparison mode: lexical, numeric, general, human, version, natural, collate, time, ip, cidr or host")
+	fs.StringVar(&s.timeLayout, "time-layout", "", "Layout of -mode time keys: rfc3339, syslog, apache, epoch or a Go layout, default: detect")
+	fs.StringVar(&s.timeZone, "time-zone", "UTC", "Time zone for times that carry none, e.g. Local or Europe/Berlin")
+	fs.StringVar(&s.badTime, "bad-time", "last", "What to do with keys -mode time cannot parse: last sorts them after all times, error fails")
+
+	fs.BoolVar(&s.regions, "regions", false, "Sort only the lines between \"keep-sorted start\" and \"keep-sorted end\" comments")
+	fs.StringVar(&s.marker, "marker", "keep-sorted", "Marker word for -regions comments")
+	fs.StringVar(&s.comment, "comment", "", "Comment syntax of -regions markers, e.g. \"#\" or \"<!-- -->\", default: #, //, -- and <!-- -->")
+	fs.BoolVar(&s.groups, "groups", false, "With -regions, sort blank-line separated groups independently")
+	fs.BoolVar(&s.contin, "continuation", false, "With -regions, keep more deeply indented lines with the line above them")
+
+	fs.BoolVar(&s.unique, "u", false, "Drop lines whose keys equal an earlier line's")
+	fs.StringVar(&s.keep, "keep", "first", "Which of a run of equal lines -u keeps: first or last")
+	fs.BoolVar(&s.count, "count", false, "Drop duplicates and prefix each line with its number of occurrences, like uniq -c")
+	fs.BoolVar(&s.dups, "dups", false, "Output one copy of each duplicated line only, like uniq -d")
+	fs.BoolVar(&s.singles, "singles", false, "Output only lines that have no duplicates, like uniq -u")
+	fs.BoolVar(&s.collapse, "collapse", false, "With -mode cidr, drop CIDR blocks that lie within an earlier block")
+
+	fs.IntVar(&s.top, "top", 0, "Output only the `N` lines that sort last, greatest first, holding no more than N in memory")
+	fs.IntVar(&s.bottom, "bottom", 0, "Output only the `N` lines that sort first, holding no more than N in memory")
+	fs.BoolVar(&s.shuffle, "shuffle", false, "Output the lines in random order instead of sorting them")
+	fs.IntVar(&s.sample, "sample", 0, "Output `K` lines chosen at random, in input order, holding no more than K in memory")
+	fs.Int64Var(&s.seed, "seed", 0, "Seed for -shuffle and -sample, so that runs can be repeated; 0 picks one at random")
+
+	fs.StringVar(&s.preset, "preset", "", "Sort a file format keeping its groups and comments: goimports, gomod, gitignore, codeowners or requirements")
+	fs.StringVar(&s.local, "local", "", "With -preset goimports, group imports under these comma-separated `prefixes` last, default: the module of the nearest go.mod")
+
+	fs.StringVar(&s.recFormat, "format", "lines", "Input format: lines, csv, tsv or jsonl records, or json or yaml documents to canonicalize")
+	fs.Var(&s.fields, "by", "Sort records by column name or number, or JSON path such as .user.id, with optional `field[:OPTS]` key options; may be repeated")
+	fs.Var(&s.arrays, "array", "With -format json or yaml, sort the arrays at a `path[=field]` such as .users=.name; * matches any key; may be repeated")
+	fs.BoolVar(&s.header, "header", true, "Keep the first CSV or TSV record in place as a header")
+
+	fs.BoolVar(&s.paragraphs, "paragraphs", false, "Sort blank-line separated paragraphs as units")
+	fs.StringVar(&s.recordSep, "record-sep", "", "Sort multi-line records separated by lines matching this regexp")
+	fs.StringVar(&s.recordStart, "record-start", "", "Sort multi-line records that each begin with a line matching this regexp")
+	fs.StringVar(&s.recordKey, "record-key", "", "Sort multi-line records by the first submatch of this regexp instead of their first line")
+	return s
+}
+
+// sorter turns the flag values into a Sorter.
+func (s *settings) sorter() (*sordid.Sorter, error) {
+	var opts []sordid.Option
+	for _, k := range s.keys {
+		opts = append(opts, sordid.Key(k))
+	}
+	for _, f := range s.fields {
+		opts = append(opts, sordid.By(f))
+	}
+	for _, a := range s.arrays {
+		opts = append(opts, sordid.Array(a))
+	}
+	mode := s.mode
+	if s.numeric {
+		mode = "numeric"
+	}
+	opts = append(opts,
+		sordid.Separator(s.sep),
+		sordid.Mode(mode),
+		sordid.TempDir(s.tmpDir),
+		sordid.TimeZone(s.timeZone),
+		sordid.Parallel(s.parallel),
+		sordid.Keep(s.keep),
+		sordid.Format(s.recFormat),
+		sordid.RecordSeparator(s.recordSep),
+		sordid.RecordStart(s.recordStart),
+		sordid.RecordKey(s.recordKey),
+	)
+	if s.memory != "" {
+		limit, err := sordid.ParseSize(s.memory)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, sordid.MemoryLimit(limit))
+	}
+	if s.locale != "" {
+		opts = append(opts, sordid.Locale(s.locale))
+	}
+	if s.timeLayout != "" {
+		opts = append(opts, sordid.TimeLayout(s.timeLayout))
+	}
+	switch s.badTime {
+	case "last":
+	case "error":
+		opts = append(opts, sordid.StrictTime())
+	default:
+		return nil, errors.New("-bad-time must be last or error, not " + s.badTime)
+	}
+	if s.regions {
+		opts = append(opts, sordid.Regions(s.marker, s.comment))
+	}
+	if s.preset != "" {
+		opts = append(opts, sordid.Preset(s.preset), sordid.LocalImports(s.local))
+	}
+	seed := s.seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	if s.top > 0 {
+		opts = append(opts, sordid.Top(s.top))
+	}
+	if s.bottom > 0 {
+		opts = append(opts, sordid.Bottom(s.bottom))
+	}
+	if s.shuffle {
+		opts = append(opts, sordid.Shuffle(seed))
+	}
+	if s.sample > 0 {
+		opts = append(opts, sordid.Sample(s.sample, seed))
+	}
+
+	for _, o := range []struct {
+		set bool
+		opt func() sordid.Option
+	}{
+		{s.nul, sordid.NUL},
+		{s.reverse, sordid.Reverse},
+		{s.skipBlanks, sordid.SkipBlanks},
+		{s.foldCase, sordid.FoldCase},
+		{s.foldAccents, sordid.FoldAccents},
+		{s.dictionary, sordid.Dictionary},
+		{s.printable, sordid.Printable},
+		{s.groups, sordid.RegionGroups},
+		{s.contin, sordid.RegionContinuation},
+		{s.unique, sordid.Unique},
+		{s.count, sordid.Count},
+		{s.dups, sordid.OnlyDuplicates},
+		{s.singles, sordid.OnlySingles},
+		{s.collapse, sordid.CollapseCIDRs},
+		{!s.header, sordid.NoHeader},
+		{s.paragraphs, sordid.Paragraphs},
+	} {
+		if o.set {
+			opts = append(opts, o.opt())
+		}
+	}
+	return sordid.New(opts...)
+}
+
+// sorterFor returns the Sorter for path: the rule that matches it in
+// the governing config file, overridden by whatever was given on the
+// command line.
+func (s *settings) sorterFor(path string, args []string) (*sordid.Sorter, error) {
+	if s.noConfig || path == sordid.Stdio {
+		return s.forPath(path).sorter()
+	}
+	r, err := findRule(s.config, path)
 	if err != nil {
 		return nil, err
 	}
-	defer file.Close()
+	if r == nil {
+		return s.forPath(path).sorter()
+	}
+
+	// Parse the command line again on top of the rule, so that flags
+	// given there win over the rule's.
+	fs := flag.NewFlagSet("sordid", flag.ContinueOnError)
+	rs := defineFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if err := r.apply(fs); err != nil {
+		return nil, err
+	}
+	return rs.forPath(path).sorter()
+}
 
-	var lines []string
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		lines = append(lines, scanner.Text())
+// forPath fills in the settings that default to something found near
+// path: the local imports of Go source are those of its module.
+func (s *settings) forPath(path string) *settings {
+	if s.preset != "goimports" || s.local != "" || path == sordid.Stdio {
+		return s
 	}
-	return lines, scanner.Err()
+	c := *s
+	c.local = modulePath(path)
+	return &c
 }
 
-// writeLines writes the lines to the given file.
-func writeLines(lines []string, path string) error {
-	file, err := os.Create(path)
+// modulePath returns the module path declared by the nearest go.mod
+// in the directory of path or above it, or "" if there is none.
+func modulePath(path string) string {
+	abs, err := filepath.Abs(path)
 	if err != nil {
-		return err
+		return ""
+	}
+	for dir := filepath.Dir(abs); ; dir = filepath.Dir(dir) {
+		data, err := ioutil.ReadFile(filepath.Join(dir, "go.mod"))
+		if err == nil {
+			for _, line := range strings.Split(string(data), "\n") {
+				if f := strings.Fields(line); len(f) == 2 && f[0] == "module" {
+					return strings.Trim(f[1], "\"`")
+				}
+			}
+			return ""
+		}
+		if dir == filepath.Dir(dir) {
+			return ""
+		}
 	}
-	defer file.Close()
+}
 
-	w := bufio.NewWriter(file)
-	for _, line := range lines {
-		fmt.Fprintln(w, line)
+// walk replaces each directory among files with the files under it
+// that the -R flags select. Backups that -backup left are never among
+// them, so a second run neither sorts them nor backs them up in turn.
+func (s *settings) walk(files []string) ([]string, error) {
+	tree := &treeSpec{include: s.include, exclude: s.exclude, gitignore: s.gitignore, backup: s.backup}
+	var all []string
+	for _, f := range files {
+		if fi, err := os.Stat(f); err != nil || !fi.IsDir() {
+			all = append(all, f)
+			continue
+		}
+		found, err := tree.files(f)
+		if err != nil {
+			return nil, fmt.Errorf("failed to walk %s: %s", f, err)
+		}
+		all = append(all, found...)
 	}
-	return w.Flush()
+	return all, nil
 }
 
 func main() {
-	var file string
-	flag.StringVar(&file, "f", "", "File to sort")
+	s := defineFlags(flag.CommandLine)
 	flag.Parse()
 
-	if file == "" {
-		if len(flag.Args()) > 0 && os.Args[1] != "" {
-			file = os.Args[1]
-		} else {
-			flag.PrintDefaults()
-			os.Exit(2)
+	var files []string
+	if s.file != "" {
+		files = append(files, s.file)
+	}
+	for _, f := range flag.Args() {
+		if f != "" {
+			files = append(files, f)
 		}
 	}
+	if len(files) == 0 {
+		files = []string{sordid.Stdio}
+	}
 
-	lines, err := readLines(file)
-	if err != nil {
-		log.Fatalf("failed to read: %s", err)
+	// Sort everything into one output when asked to, or when reading
+	// standard input, which has nowhere to be rewritten in place.
+	combined := s.output != "" || s.merge
+	for _, f := range files {
+		if f == sordid.Stdio {
+			combined = true
+		}
+	}
+	if combined && s.output == "" {
+		s.output = sordid.Stdio
 	}
 
-	sort.Strings(lines)
+	if combined && (s.dryRun || s.backup != "" || s.undo) {
+		log.Fatal("-dry-run, -backup and -undo only apply to files sorted in place")
+	}
+	if combined && !s.check {
+		// The combined output follows the rules for its own path.
+		sorter, err := s.sorterFor(s.output, os.Args[1:])
+		if err != nil {
+			log.Fatal(err)
+		}
+		sortAll := sorter.SortFiles
+		if s.merge {
+			sortAll = sorter.MergeFiles
+		}
+		if err := sortAll(s.output, files...); err != nil {
+			log.Fatalf("failed to sort: %s", err)
+		}
+		return
+	}
 
-	if err := writeLines(lines, file); err != nil {
-		log.Fatalf("failed to write file: %s", err)
+	if s.recursive {
+		var err error
+		if files, err = s.walk(files); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if s.undo {
+		if s.backup == "" {
+			log.Fatal("-undo needs the -backup suffix to restore from")
+		}
+		status := 0
+		for _, f := range files {
+			err := sordid.Restore(f, s.backup)
+			if s.recursive && os.IsNotExist(err) {
+				// Walks find files that were never backed up.
+				continue
+			}
+			if err != nil {
+				log.Printf("failed to restore %s: %s", f, err)
+				status = 2
+			}
+		}
+		os.Exit(status)
 	}
 
+	// Exit 1 when a checked file is out of order or a dry run would
+	// change one, 2 when any file failed.
+	status := 0
+	var changed, sorted, failed int
+	for _, f := range files {
+		sorter, err := s.sorterFor(f, os.Args[1:])
+		if err != nil {
+			log.Printf("bad sort rules for %s: %s", f, err)
+			status = 2
+			failed++
+			continue
+		}
+		if s.check {
+			ok, err := sorter.CheckFile(f, os.Stdout, s.showDiff)
+			if err != nil {
+				log.Printf("failed to check %s: %s", f, err)
+				status = 2
+			} else if !ok && status == 0 {
+				status = 1
+			}
+			continue
+		}
+
+		rw := sordid.Rewrite{Backup: s.backup, DryRun: s.dryRun}
+		if s.showDiff || s.dryRun {
+			rw.Diff = os.Stdout
+		}
+		ok, err := sorter.Rewrite(f, rw)
+		switch {
+		case err != nil:
+			log.Printf("failed to sort %s: %s", f, err)
+			status = 2
+			failed++
+		case !ok:
+			sorted++
+		default:
+			changed++
+			if s.dryRun && status == 0 {
+				status = 1
+			}
+		}
+	}
+
+	if (s.recursive || s.dryRun) && !s.check {
+		verb := "changed"
+		if s.dryRun {
+			verb = "would change"
+		}
+		fmt.Fprintf(os.Stderr, "%d %s, %d already sorted, %d failed\n", changed, verb, sorted, failed)
+	}
+	os.Exit(status)
 }
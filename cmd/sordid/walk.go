@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/karrick/godirwalk"
+)
+
+// treeSpec picks the files to sort under a directory. Patterns follow
+// matchGlob, relative to the directory walked; one without a slash
+// matches the base name at any depth.
+type treeSpec struct {
+	include   []string // files to sort; none means every file
+	exclude   []string // files and directories to leave out
+	gitignore bool     // leave out what .gitignore files ignore
+	backup    string   // leave out files ending in this -backup suffix
+}
+
+// files returns the regular files under root that t selects, in
+// lexical order. Version control directories are always skipped.
+func (t *treeSpec) files(root string) ([]string, error) {
+	var files []string
+	var ignores []ignoreRule
+	err := godirwalk.Walk(root, &godirwalk.Options{
+		Callback: func(osPathname string, de *godirwalk.Dirent) error {
+			rel, err := filepath.Rel(root, osPathname)
+			if err != nil {
+				return err
+			}
+			if rel == "." {
+				rel = ""
+			}
+			rel = filepath.ToSlash(rel)
+			dir := de.IsDir()
+
+			if rel != "" {
+				if dir && de.Name() == ".git" {
+					return godirwalk.SkipThis
+				}
+				excluded, err := matchAny(t.exclude, rel)
+				if err != nil {
+					return err
+				}
+				if excluded || (t.gitignore && ignored(ignores, rel, dir)) {
+					if dir {
+						return godirwalk.SkipThis
+					}
+					return nil
+				}
+			}
+
+			if dir {
+				if t.gitignore {
+					rules, err := readIgnore(filepath.Join(osPathname, ".gitignore"), rel)
+					if err != nil {
+						return err
+					}
+					ignores = append(ignores, rules...)
+				}
+				return nil
+			}
+			if !de.IsRegular() || (t.backup != "" && strings.HasSuffix(rel, t.backup)) {
+				return nil
+			}
+			if len(t.include) > 0 {
+				included, err := matchAny(t.include, rel)
+				if err != nil || !included {
+					return err
+				}
+			}
+			files = append(files, osPathname)
+			return nil
+		},
+		Unsorted: false,
+	})
+	sort.Strings(files)
+	return files, err
+}
+
+// ignoreRule is one pattern line of a .gitignore file.
+type ignoreRule struct {
+	base     string // directory holding the .gitignore, relative to the walk
+	pattern  string
+	negate   bool // "!" re-includes what an earlier rule ignored
+	dirOnly  bool // a trailing "/" matches directories only
+	anchored bool // a slash before the end ties the pattern to base
+}
+
+// readIgnore reads the rules of a .gitignore file in the directory
+// base. A missing file has no rules.
+func readIgnore(name, base string) ([]ignoreRule, error) {
+	f, err := os.Open(name)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close() //nolint:errcheck
+
+	var rules []ignoreRule
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		line := strings.TrimRight(s.Text(), " \t\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		r := ignoreRule{base: base}
+		if strings.HasPrefix(line, "!") {
+			r.negate, line = true, line[1:]
+		}
+		line = strings.TrimPrefix(line, `\`)
+		if strings.HasSuffix(line, "/") {
+			r.dirOnly, line = true, strings.TrimRight(line, "/")
+		}
+		r.anchored = strings.Contains(line, "/")
+		r.pattern = strings.TrimPrefix(line, "/")
+		if r.pattern != "" {
+			rules = append(rules, r)
+		}
+	}
+	return rules, s.Err()
+}
+
+// ignored reports whether the last rule matching rel ignores it.
+func ignored(rules []ignoreRule, rel string, dir bool) bool {
+	ignore := false
+	for _, r := range rules {
+		if r.dirOnly && !dir {
+			continue
+		}
+		name := rel
+		if r.base != "" {
+			if !strings.HasPrefix(rel, r.base+"/") {
+				continue
+			}
+			name = rel[len(r.base)+1:]
+		}
+		if !r.anchored {
+			name = path.Base(name)
+		}
+		if ok, _ := matchGlob(r.pattern, name); ok {
+			ignore = !r.negate
+		}
+	}
+	return ignore
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tydavis/utilities/pkg/sordid"
+)
+
+func TestTreeFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sordid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for name, content := range map[string]string{
+		".gitignore":                 "*.tmp\n/build/\n!keep.tmp\n",
+		"allowlists/a.txt":           "",
+		"allowlists/b.tmp":           "",
+		"allowlists/keep.tmp":        "",
+		"allowlists/vendor/c.txt":    "",
+		"allowlists/deep/.gitignore": "local.txt\n",
+		"allowlists/deep/local.txt":  "",
+		"allowlists/deep/d.txt":      "",
+		"build/e.txt":                "",
+		"other/build/f.txt":          "",
+		".git/g.txt":                 "",
+		"notes.md":                   "",
+	} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0640); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		tree treeSpec
+		want string
+	}{
+		{
+			treeSpec{include: []string{"allowlists/**/*.txt"}, exclude: []string{"vendor"}, gitignore: true},
+			"allowlists/a.txt allowlists/deep/d.txt",
+		},
+		{
+			treeSpec{gitignore: true},
+			".gitignore allowlists/a.txt allowlists/deep/.gitignore allowlists/deep/d.txt allowlists/keep.tmp allowlists/vendor/c.txt notes.md other/build/f.txt",
+		},
+		{
+			treeSpec{include: []string{"*.txt"}},
+			"allowlists/a.txt allowlists/deep/d.txt allowlists/deep/local.txt allowlists/vendor/c.txt build/e.txt other/build/f.txt",
+		},
+	}
+	for _, test := range tests {
+		files, err := test.tree.files(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, f := range files {
+			rel, err := filepath.Rel(dir, f)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, filepath.ToSlash(rel))
+		}
+		if strings.Join(got, " ") != test.want {
+			t.Errorf("%+v: expected %s, got %s", test.tree, test.want, strings.Join(got, " "))
+		}
+	}
+}
+
+func TestWalkSkipsBackups(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sordid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "x.txt")
+	write := func(content string) {
+		if err := ioutil.WriteFile(path, []byte(content), 0640); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s := &settings{recursive: true, backup: ".bak"}
+	sorter, err := sordid.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Sort twice with -R -backup, changing the file in between.
+	write("b\na\n")
+	for _, content := range []string{"", "d\nc\n"} {
+		if content != "" {
+			write(content)
+		}
+		files, err := s.walk([]string{dir})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(files) != 1 || files[0] != path {
+			t.Fatalf("expected only %s, got %v", path, files)
+		}
+		if _, err := sorter.Rewrite(files[0], sordid.Rewrite{Backup: s.backup}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := os.Stat(path + ".bak.bak"); !os.IsNotExist(err) {
+		t.Errorf("expected no backup of the backup, got %v", err)
+	}
+
+	// -R -undo restores what the last run replaced.
+	files, err := s.walk([]string{dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range files {
+		if err := sordid.Restore(f, s.backup); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got, _ := ioutil.ReadFile(path); string(got) != "d\nc\n" {
+		t.Errorf("expected restored %q, got %q", "d\nc\n", got)
+	}
+	path += ".bak"
+	write("b\na\n")
+	if _, err := sorter.Rewrite(path, sordid.Rewrite{Backup: ".bak"}); err == nil || !strings.Contains(err.Error(), "backup") {
+		t.Errorf("expected backing up a backup to fail, got %v", err)
+	}
+}
@@ -5,5 +5,7 @@ go 1.15
 require (
 	github.com/karrick/godirwalk v1.16.1
 	golang.org/x/crypto v0.0.0-20201012173705-84dcc777aaee
-	golang.org/x/sys v0.0.0-20201009025420-dfb3f7c4e634 // indirect
+	golang.org/x/sys v0.0.0-20201009025420-dfb3f7c4e634
+	golang.org/x/text v0.3.7
+	gopkg.in/yaml.v3 v3.0.1
 )
@@ -0,0 +1,70 @@
+package sordid
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// atomicWrite replaces the file at path with what fill writes, without
+// ever leaving a partial file behind. The output goes to a temporary
+// file in the same directory, which takes on the original's mode,
+// owner and extended attributes, is synced, and is renamed into place.
+func atomicWrite(path string, fill func(w io.Writer) error) error {
+	// Write through symlinks rather than replacing them.
+	target, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return err
+	}
+	info, err := os.Stat(target)
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(target)
+	tmp, err := ioutil.TempFile(dir, "."+filepath.Base(target)+".sordid-")
+	if err != nil {
+		return err
+	}
+	committed := false
+	defer func() {
+		if !committed {
+			tmp.Close()           //nolint:errcheck
+			os.Remove(tmp.Name()) //nolint:errcheck
+		}
+	}()
+
+	if err := fill(tmp); err != nil {
+		return err
+	}
+	if err := copyMetadata(tmp, target, info); err != nil {
+		return err
+	}
+	if err := tmp.Chmod(info.Mode()); err != nil {
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), target); err != nil {
+		return err
+	}
+	committed = true
+	syncDir(dir)
+	return nil
+}
+
+// syncDir flushes the rename to disk. Not every platform can sync
+// a directory, so this is best effort.
+func syncDir(dir string) {
+	d, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	d.Sync()  //nolint:errcheck
+	d.Close() //nolint:errcheck
+}
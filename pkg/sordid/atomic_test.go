@@ -0,0 +1,83 @@
+package sordid
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSortFileKeepsFormat(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sordid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		in, want string
+	}{
+		{"b\na\nc\n", "a\nb\nc\n"},
+		{"b\na\nc", "a\nb\nc"},
+		{"b\r\na\r\nc\r\n", "a\r\nb\r\nc\r\n"},
+		{"b\r\na\r\nc", "a\r\nb\r\nc"},
+		{"\xEF\xBB\xBFb\na\n", "\xEF\xBB\xBFa\nb\n"},
+		{"", ""},
+	}
+	for _, test := range tests {
+		// Small and unlimited memory exercise the merge and in-memory paths.
+		for _, limit := range []int64{1, 0} {
+			path := filepath.Join(dir, "input")
+			if err := ioutil.WriteFile(path, []byte(test.in), 0640); err != nil {
+				t.Fatal(err)
+			}
+			o := &options{cmp: newComparer(nil, "", keyOpts{}), memLimit: limit, tmpDir: dir}
+			if err := sortFile(path, o); err != nil {
+				t.Fatal(err)
+			}
+			got, err := ioutil.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != test.want {
+				t.Errorf("limit %d: for %q expected %q, got %q", limit, test.in, test.want, got)
+			}
+		}
+	}
+}
+
+func TestAtomicWriteKeepsMode(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sordid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "input")
+	if err := ioutil.WriteFile(path, []byte("b\na\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(dir, "link")
+	if err := os.Symlink(path, link); err != nil {
+		t.Skip("symlinks unavailable:", err)
+	}
+
+	o := &options{cmp: newComparer(nil, "", keyOpts{})}
+	if err := sortFile(link, o); err != nil {
+		t.Fatal(err)
+	}
+
+	if fi, err := os.Lstat(link); err != nil || fi.Mode()&os.ModeSymlink == 0 {
+		t.Error("symlink was replaced", err)
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm() != 0600 {
+		t.Errorf("expected mode 0600, got %v", fi.Mode().Perm())
+	}
+	if left, _ := filepath.Glob(filepath.Join(dir, ".input.sordid-*")); len(left) != 0 {
+		t.Error("temporary files left behind:", left)
+	}
+}
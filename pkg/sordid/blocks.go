@@ -0,0 +1,178 @@
+package sordid
+
+import (
+	"errors"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// blockSpec sorts multi-line records, such as paragraphs or INI
+// sections, as units. Records are either split apart by separator
+// lines or begun by lines matching start.
+type blockSpec struct {
+	sep   *regexp.Regexp // lines between records
+	start *regexp.Regexp // the first line of each record
+	key   *regexp.Regexp // sort key taken from the record; nil uses its first line
+}
+
+// blankLine matches the separators of paragraph records.
+var blankLine = regexp.MustCompile(`^\s*$`)
+
+// newBlockSpec compiles the record patterns. Exactly one of
+// paragraphs, sep and start picks how records are told apart.
+func newBlockSpec(paragraphs bool, sep, start, key string) (*blockSpec, error) {
+	n := 0
+	for _, set := range []bool{paragraphs, sep != "", start != ""} {
+		if set {
+			n++
+		}
+	}
+	if n != 1 {
+		return nil, errors.New("use only one of -paragraphs, -record-sep and -record-start")
+	}
+
+	b := &blockSpec{sep: blankLine}
+	var err error
+	switch {
+	case sep != "":
+		b.sep, err = regexp.Compile(sep)
+	case start != "":
+		b.sep = nil
+		b.start, err = regexp.Compile(start)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if key != "" {
+		if b.key, err = regexp.Compile(key); err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+// block is one multi-line record and its sort key.
+type block struct {
+	lines []string
+	sep   []string // separator lines read after the record
+	key   string
+	last  bool // read last, so with no separator lines of its own
+}
+
+// keyOf returns the text a record is sorted by: the first submatch of
+// the key pattern, or the whole match when it has no groups.
+func (b *blockSpec) keyOf(lines []string) string {
+	if b.key == nil {
+		return lines[0]
+	}
+	m := b.key.FindStringSubmatch(strings.Join(lines, "\n"))
+	switch {
+	case m == nil:
+		return ""
+	case len(m) > 1:
+		return m[1]
+	}
+	return m[0]
+}
+
+// sortBlocks splits lines into records, sorts them by key and puts
+// them back together.
+func sortBlocks(lines []string, o *options) []string {
+	b := o.blocks
+	if b.start != nil {
+		return b.sortStarted(lines, o.cmp)
+	}
+
+	// Separator lines at either end of the file stay there.
+	head := 0
+	for head < len(lines) && b.sep.MatchString(lines[head]) {
+		head++
+	}
+	tail := len(lines)
+	for tail > head && b.sep.MatchString(lines[tail-1]) {
+		tail--
+	}
+
+	var blocks []*block
+	for i := head; i < tail; {
+		j := i
+		for j < tail && !b.sep.MatchString(lines[j]) {
+			j++
+		}
+		blk := &block{lines: lines[i:j], key: b.keyOf(lines[i:j])}
+		i = j
+		for j < tail && b.sep.MatchString(lines[j]) {
+			j++
+		}
+		blk.sep, blk.last = lines[i:j], j == tail
+		blocks = append(blocks, blk)
+		i = j
+	}
+	sortByKey(blocks, o.cmp)
+
+	out := append([]string(nil), lines[:head]...)
+	out = joinBlocks(out, blocks)
+	return append(out, lines[tail:]...)
+}
+
+// sortStarted sorts records that each begin with a line matching the
+// start pattern. Lines ahead of the first record stay at the top, and
+// each record keeps the blank lines that followed it.
+func (b *blockSpec) sortStarted(lines []string, cmp *comparer) []string {
+	first := 0
+	for first < len(lines) && !b.start.MatchString(lines[first]) {
+		first++
+	}
+
+	var blocks []*block
+	var trailing []string
+	for i := first; i < len(lines); {
+		j := i + 1
+		for j < len(lines) && !b.start.MatchString(lines[j]) {
+			j++
+		}
+		body := lines[i:j]
+		end := len(body)
+		for end > 1 && blankLine.MatchString(body[end-1]) {
+			end--
+		}
+		blk := &block{lines: body[:end], key: b.keyOf(body[:end])}
+		if j == len(lines) {
+			trailing, blk.last = body[end:], true
+		} else {
+			blk.sep = body[end:]
+		}
+		blocks = append(blocks, blk)
+		i = j
+	}
+	sortByKey(blocks, cmp)
+
+	out := append([]string(nil), lines[:first]...)
+	out = joinBlocks(out, blocks)
+	return append(out, trailing...)
+}
+
+// joinBlocks appends the sorted records to out, each followed by the
+// separator lines read after it, so that none are lost. The record
+// read last had none of its own; it takes those of the record that now
+// comes last, which would otherwise trail the file.
+func joinBlocks(out []string, blocks []*block) []string {
+	if n := len(blocks); n > 0 {
+		for _, blk := range blocks[:n-1] {
+			if blk.last {
+				blk.sep, blocks[n-1].sep = blocks[n-1].sep, nil
+			}
+		}
+	}
+	for _, blk := range blocks {
+		out = append(out, blk.lines...)
+		out = append(out, blk.sep...)
+	}
+	return out
+}
+
+// sortByKey sorts records by their keys, keeping ties in input order.
+func sortByKey(blocks []*block, cmp *comparer) {
+	sort.SliceStable(blocks, func(i, j int) bool { return cmp.less(blocks[i].key, blocks[j].key) })
+}
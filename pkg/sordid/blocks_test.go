@@ -0,0 +1,72 @@
+package sordid
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSortBlocks(t *testing.T) {
+	tests := []struct {
+		paragraphs bool
+		sep, start string
+		key        string
+		opts       keyOpts
+		in, want   string
+	}{
+		{
+			true, "", "", "", keyOpts{},
+			"\nb\nb2\n\n\na\na2\n\n",
+			"\na\na2\n\n\nb\nb2\n\n",
+		},
+		{
+			true, "", "", "", keyOpts{},
+			"c\n\nb\n\n\n\na",
+			"a\n\nb\n\n\n\nc",
+		},
+		{
+			false, "^---", "", "", keyOpts{},
+			"b\n--- two\na\n--- one\nc",
+			"a\n--- one\nb\n--- two\nc",
+		},
+		{
+			false, "^---", "", "", keyOpts{},
+			"c\n--- one\n--- two\nb\n--- three\na",
+			"a\n--- one\n--- two\nb\n--- three\nc",
+		},
+		{
+			false, "^%$", "", "", keyOpts{},
+			"q2\n%\nq1\nmore\n%\nq0",
+			"q0\n%\nq1\nmore\n%\nq2",
+		},
+		{
+			false, "", `^\[`, "", keyOpts{},
+			"; preamble\n[b]\nx=1\n\n[a]\ny=2",
+			"; preamble\n[a]\ny=2\n\n[b]\nx=1",
+		},
+		{
+			false, "", `^\[`, "", keyOpts{},
+			"[c]\n[b]\n\n\n[a]\n\n",
+			"[a]\n[b]\n\n\n[c]\n\n",
+		},
+		{
+			false, "", "^## ", `^## v(\S+)`, keyOpts{mode: modeVersion, reverse: true},
+			"# Changelog\n## v1.9.0\n- old\n\n## v1.10.0\n- new\n\n",
+			"# Changelog\n## v1.10.0\n- new\n\n## v1.9.0\n- old\n\n",
+		},
+	}
+	for _, test := range tests {
+		b, err := newBlockSpec(test.paragraphs, test.sep, test.start, test.key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		o := &options{cmp: newComparer(nil, "", test.opts), blocks: b}
+		got := strings.Join(sortBlocks(strings.Split(test.in, "\n"), o), "\n")
+		if got != test.want {
+			t.Errorf("for %q expected %q, got %q", test.in, test.want, got)
+		}
+	}
+
+	if _, err := newBlockSpec(true, "x", "", ""); err == nil {
+		t.Error("expected error for two record modes")
+	}
+}
@@ -0,0 +1,230 @@
+package sordid
+
+import (
+	"fmt"
+	"io"
+)
+
+// checkFile reports whether the file at path is already sorted,
+// writing the first out-of-order line to w if it is not. With
+// showDiff set, a unified diff of what sorting would change follows.
+func checkFile(path string, o *options, showDiff bool, w io.Writer) (bool, error) {
+	if o.records != nil {
+		return checkRecords(path, o, showDiff, w)
+	}
+	if o.document != nil {
+		return checkDocument(path, o, showDiff, w)
+	}
+	if o.pick != nil {
+		return checkPick(path, o, showDiff, w)
+	}
+	if o.wholeFile() || o.filtered() {
+		return checkWholeFile(path, o, showDiff, w)
+	}
+
+	in, err := openInput(path, o.nul)
+	if err != nil {
+		return false, err
+	}
+	defer in.Close() //nolint:errcheck
+
+	var lines []string
+	var prev string
+	bad := 0
+	s := in.scanner
+	for n := 1; s.Scan(); n++ {
+		line := s.Text()
+		if n > 1 && bad == 0 && o.cmp.compare(prev, line) > 0 {
+			bad = n
+			if !showDiff {
+				break
+			}
+		}
+		if showDiff {
+			lines = append(lines, line)
+		}
+		prev = line
+	}
+	if err := s.Err(); err != nil {
+		return false, err
+	}
+	if err := o.timeErr(); err != nil {
+		return false, err
+	}
+	if bad == 0 {
+		return true, nil
+	}
+
+	fmt.Fprintf(w, "%s:%d: out of order, sorts before line %d\n", path, bad, bad-1)
+	if showDiff {
+		sorted, err := sortLines(lines, o)
+		if err != nil {
+			return false, err
+		}
+		return false, unifiedDiff(w, path, path+" (sorted)", lines, sorted)
+	}
+	return false, nil
+}
+
+// checkWholeFile checks a file by sorting it in memory and comparing
+// the result, for modes where sorted order is not line-to-line.
+func checkWholeFile(path string, o *options, showDiff bool, w io.Writer) (bool, error) {
+	in, err := openInput(path, o.nul)
+	if err != nil {
+		return false, err
+	}
+	defer in.Close() //nolint:errcheck
+
+	var lines []string
+	for in.scanner.Scan() {
+		lines = append(lines, in.scanner.Text())
+	}
+	if err := in.scanner.Err(); err != nil {
+		return false, err
+	}
+	sorted, err := sortLines(lines, o)
+	if err != nil {
+		return false, err
+	}
+
+	bad := firstDifference(lines, sorted)
+	if bad < 0 {
+		return true, nil
+	}
+	fmt.Fprintf(w, "%s:%d: out of order\n", path, bad+1)
+	if showDiff {
+		return false, unifiedDiff(w, path, path+" (sorted)", lines, sorted)
+	}
+	return false, nil
+}
+
+// checkPick checks that a file holds just the lines picked from it,
+// reading it twice so as to hold no more than those in memory. The
+// picked lines are some of the file's, so it cannot have fewer.
+func checkPick(path string, o *options, showDiff bool, w io.Writer) (bool, error) {
+	in, err := openInput(path, o.nul)
+	if err != nil {
+		return false, err
+	}
+	picked, err := pickInputs([]*input{in}, o)
+	in.Close() //nolint:errcheck
+	if err != nil {
+		return false, err
+	}
+
+	if in, err = openInput(path, o.nul); err != nil {
+		return false, err
+	}
+	defer in.Close() //nolint:errcheck
+
+	var lines []string
+	bad := -1
+	s := in.scanner
+	for n := 0; s.Scan(); n++ {
+		line := s.Text()
+		if bad < 0 && (n >= len(picked) || picked[n] != line) {
+			bad = n
+			if !showDiff {
+				break
+			}
+		}
+		if showDiff {
+			lines = append(lines, line)
+		}
+	}
+	if err := s.Err(); err != nil {
+		return false, err
+	}
+	if bad < 0 {
+		return true, nil
+	}
+
+	fmt.Fprintf(w, "%s:%d: differs from the lines picked\n", path, bad+1)
+	if showDiff {
+		return false, unifiedDiff(w, path, path+" (sorted)", lines, picked)
+	}
+	return false, nil
+}
+
+// checkRecords checks that the structured records of a file are in
+// order, reporting the first one that is not by its record number.
+func checkRecords(path string, o *options, showDiff bool, w io.Writer) (bool, error) {
+	in, err := openInput(path, o.nul)
+	if err != nil {
+		return false, err
+	}
+	defer in.Close() //nolint:errcheck
+
+	header, recs, err := readRecords([]*input{in}, o.records, in.format)
+	if err != nil {
+		return false, err
+	}
+	sorted := append([]*record(nil), recs...)
+	o.records.sortRecords(sorted)
+	if err := o.timeErr(); err != nil {
+		return false, err
+	}
+
+	for i, rec := range sorted {
+		if rec.index == i {
+			continue
+		}
+		n := i + 1
+		if header != nil {
+			n++
+		}
+		fmt.Fprintf(w, "%s: record %d out of order\n", path, n)
+		if showDiff {
+			return false, unifiedDiff(w, path, path+" (sorted)",
+				o.records.recordLines(header, recs), o.records.recordLines(header, sorted))
+		}
+		return false, nil
+	}
+	return true, nil
+}
+
+// checkDocument checks that a JSON or YAML file is in canonical form.
+func checkDocument(path string, o *options, showDiff bool, w io.Writer) (bool, error) {
+	in, err := openInput(path, o.nul)
+	if err != nil {
+		return false, err
+	}
+	defer in.Close() //nolint:errcheck
+
+	data, err := readDocument([]*input{in})
+	if err != nil {
+		return false, err
+	}
+	sorted, err := o.document.sortDocument(data, o.cmp)
+	if err != nil {
+		return false, err
+	}
+	if err := o.timeErr(); err != nil {
+		return false, err
+	}
+
+	lines, sortedLines := documentLines(data), documentLines(sorted)
+	bad := firstDifference(lines, sortedLines)
+	if bad < 0 {
+		return true, nil
+	}
+	fmt.Fprintf(w, "%s:%d: not in canonical order\n", path, bad+1)
+	if showDiff {
+		return false, unifiedDiff(w, path, path+" (sorted)", lines, sortedLines)
+	}
+	return false, nil
+}
+
+// firstDifference returns the index of the first line where a and b
+// differ, or -1 if they are the same.
+func firstDifference(a, b []string) int {
+	for i := range a {
+		if i >= len(b) || a[i] != b[i] {
+			return i
+		}
+	}
+	if len(b) > len(a) {
+		return len(a)
+	}
+	return -1
+}
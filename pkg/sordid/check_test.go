@@ -0,0 +1,59 @@
+package sordid
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCheckFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sordid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "f")
+
+	tests := []struct {
+		opts   []Option
+		in     string
+		diff   bool
+		sorted bool
+		want   string
+	}{
+		{nil, "a\nb\nb\nc\n", false, true, ""},
+		{nil, "", false, true, ""},
+		{nil, "a\nc\nb\nd\n", false, false, "f:3: out of order, sorts before line 2\n"},
+		{nil, "b\na\n", true, false,
+			"f:2: out of order, sorts before line 1\n--- f\n+++ f (sorted)\n@@ -1,2 +1,2 @@\n-b\n a\n+b\n"},
+		{[]Option{Key("1,1n")}, "2\n10\n", false, true, ""},
+		{[]Option{Key("1,1n")}, "10\n2\n", false, false, "f:2: out of order, sorts before line 1\n"},
+
+		// Regions and dedupe are checked by sorting the whole file.
+		{[]Option{Regions("", "#")}, "z\n# keep-sorted start\na\nb\n# keep-sorted end\ny\n", false, true, ""},
+		{[]Option{Regions("", "#")}, "z\n# keep-sorted start\nb\na\n# keep-sorted end\ny\n", true, false,
+			"f:3: out of order\n--- f\n+++ f (sorted)\n@@ -1,6 +1,6 @@\n z\n # keep-sorted start\n-b\n a\n+b\n # keep-sorted end\n y\n"},
+		{[]Option{Unique()}, "a\nb\n", false, true, ""},
+		{[]Option{Unique()}, "a\na\nb\n", false, false, "f:2: out of order\n"},
+	}
+	for i, test := range tests {
+		if err := ioutil.WriteFile(path, []byte(test.in), 0644); err != nil {
+			t.Fatal(err)
+		}
+		s, err := New(test.opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var report strings.Builder
+		sorted, err := s.CheckFile(path, &report, test.diff)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := strings.Replace(report.String(), path, "f", -1)
+		if sorted != test.sorted || got != test.want {
+			t.Errorf("%d: for %q expected %v %q, got %v %q", i, test.in, test.sorted, test.want, sorted, got)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package sordid
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/collate"
+	"golang.org/x/text/language"
+	"golang.org/x/text/unicode/norm"
+)
+
+// parseLocale checks a BCP 47 language tag such as "de" or "sv-SE".
+func parseLocale(s string) (string, error) {
+	tag, err := language.Parse(s)
+	if err != nil {
+		return "", err
+	}
+	return tag.String(), nil
+}
+
+// newCollator returns the collator for a key in modeCollate, or nil
+// for any other mode. Case and accent folding are left to the
+// collator, which knows the locale's rules for them.
+func newCollator(o *keyOpts) *collate.Collator {
+	if o.mode != modeCollate {
+		return nil
+	}
+	tag := language.Und
+	if o.locale != "" {
+		tag = language.Make(o.locale)
+	}
+
+	var opts []collate.Option
+	if o.foldCase {
+		opts = append(opts, collate.IgnoreCase)
+	}
+	if o.foldAccents {
+		opts = append(opts, collate.IgnoreDiacritics)
+	}
+	return collate.New(tag, opts...)
+}
+
+// normalize applies the key's character filters and, outside of
+// modeCollate, its case and accent folding.
+func (k *keyOpts) normalize(s string) string {
+	if k.dictionary || k.printable {
+		s = strings.Map(k.filterRune, s)
+	}
+	if k.coll == nil {
+		if k.foldAccents {
+			s = stripAccents(s)
+		}
+		if k.foldCase {
+			s = strings.Map(foldRune, s)
+		}
+	}
+	return s
+}
+
+// filterRune drops the characters that dictionary order or printable
+// only leave out of comparisons.
+func (k *keyOpts) filterRune(r rune) rune {
+	if k.dictionary && !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != ' ' && r != '\t' {
+		return -1
+	}
+	if k.printable && !unicode.IsPrint(r) {
+		return -1
+	}
+	return r
+}
+
+// foldRune maps every case variant of a rune onto the same rune, the
+// smallest in its Unicode simple folding orbit.
+func foldRune(r rune) rune {
+	min := r
+	for f := unicode.SimpleFold(r); f != r; f = unicode.SimpleFold(f) {
+		if f < min {
+			min = f
+		}
+	}
+	return min
+}
+
+// stripAccents removes combining marks, so "é" compares as "e".
+func stripAccents(s string) string {
+	d := norm.NFD.String(s)
+	d = strings.Map(func(r rune) rune {
+		if unicode.Is(unicode.Mn, r) {
+			return -1
+		}
+		return r
+	}, d)
+	return norm.NFC.String(d)
+}
@@ -0,0 +1,318 @@
+package sordid
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// compareMode selects how two keys are ordered.
+type compareMode int
+
+const (
+	modeLexical compareMode = iota
+	modeNumeric             // leading integer or decimal, as sort -n
+	modeGeneral             // floating point with exponents, as sort -g
+	modeHuman               // sizes with K, M, G... suffixes, as sort -h
+	modeVersion             // semantic or Debian-style version strings
+	modeNatural             // digit runs compare as numbers: file2 < file10
+	modeCollate             // Unicode Collation Algorithm for a locale
+	modeTime                // timestamps in a given or detected layout
+	modeIP                  // IPv4 then IPv6 addresses, numerically
+	modeCIDR                // CIDR blocks by network, then prefix length
+	modeHost                // host names by their labels, top level first
+)
+
+// modeFlags maps the key option letters onto comparison modes.
+var modeFlags = map[rune]compareMode{
+	'n': modeNumeric,
+	'g': modeGeneral,
+	'h': modeHuman,
+	'V': modeVersion,
+	'N': modeNatural,
+	'L': modeCollate,
+	'T': modeTime,
+	'I': modeIP,
+	'C': modeCIDR,
+	'H': modeHost,
+}
+
+// modeNames maps the names of comparison modes onto them.
+var modeNames = map[string]compareMode{
+	"lexical": modeLexical,
+	"numeric": modeNumeric,
+	"general": modeGeneral,
+	"human":   modeHuman,
+	"version": modeVersion,
+	"natural": modeNatural,
+	"collate": modeCollate,
+	"time":    modeTime,
+	"ip":      modeIP,
+	"cidr":    modeCIDR,
+	"host":    modeHost,
+}
+
+// parseMode looks up a comparison mode by name.
+func parseMode(name string) (compareMode, error) {
+	m, ok := modeNames[name]
+	if !ok {
+		return modeLexical, errors.New("unknown comparison mode: " + name)
+	}
+	return m, nil
+}
+
+// comparer orders lines by a list of keys, falling back to the whole
+// line when no keys are given. Lines that compare equal keep their
+// input order.
+type comparer struct {
+	keys []keySpec
+	sep  string
+}
+
+// newComparer builds a comparer, handing the global options to any key
+// that did not set its own, the way POSIX sort does.
+func newComparer(keys []keySpec, sep string, global keyOpts) *comparer {
+	c := &comparer{sep: sep}
+	if len(keys) == 0 {
+		keys = []keySpec{{startField: 1, startChar: 1}}
+	}
+	for _, k := range keys {
+		k.resolve(global, k.hasOpts)
+		c.keys = append(c.keys, k)
+	}
+	return c
+}
+
+// clone returns a comparer ordering lines the same way that can be
+// used from another goroutine.
+func (c *comparer) clone() *comparer {
+	d := &comparer{sep: c.sep, keys: append([]keySpec(nil), c.keys...)}
+	for i := range d.keys {
+		d.keys[i].coll = newCollator(&d.keys[i].keyOpts)
+		if t := d.keys[i].times; t != nil {
+			d.keys[i].times = t.clone()
+		}
+	}
+	return d
+}
+
+// resolve takes on the global options unless the key has its own,
+// and prepares the key for comparisons.
+func (k *keyOpts) resolve(global keyOpts, own bool) {
+	if !own {
+		*k = global
+	}
+	k.locale = global.locale
+	k.timeLayout, k.timeZone, k.strictTime = global.timeLayout, global.timeZone, global.strictTime
+	k.coll = newCollator(k)
+	k.times = newTimeKey(k)
+}
+
+// compare returns -1, 0 or 1 as a sorts before, with or after b.
+func (c *comparer) compare(a, b string) int {
+	for i := range c.keys {
+		k := &c.keys[i]
+		r := k.compare(k.extract(a, c.sep), k.extract(b, c.sep))
+		if r == 0 {
+			continue
+		}
+		if k.reverse {
+			return -r
+		}
+		return r
+	}
+	return 0
+}
+
+func (c *comparer) less(a, b string) bool { return c.compare(a, b) < 0 }
+
+// compare compares two extracted keys according to the key's options,
+// leaving out the direction.
+func (k *keyOpts) compare(a, b string) int {
+	a, b = k.normalize(a), k.normalize(b)
+	if k.coll != nil {
+		return k.coll.CompareString(a, b)
+	}
+	if k.times != nil {
+		return k.times.compare(a, b, k.reverse)
+	}
+	return compareKeys(k.mode, a, b)
+}
+
+// compareKeys compares two extracted keys according to mode.
+func compareKeys(mode compareMode, a, b string) int {
+	switch mode {
+	case modeNumeric:
+		return compareFloats(leadingNumber(a), leadingNumber(b))
+	case modeGeneral:
+		return compareGeneral(a, b)
+	case modeHuman:
+		return compareFloats(humanSize(a), humanSize(b))
+	case modeVersion:
+		return compareVersions(strings.TrimSpace(a), strings.TrimSpace(b))
+	case modeNatural:
+		return compareNatural(a, b)
+	case modeIP:
+		return compareIPs(a, b)
+	case modeCIDR:
+		return compareCIDRs(a, b)
+	case modeHost:
+		return compareHosts(a, b)
+	}
+	return strings.Compare(a, b)
+}
+
+// leadingNumber parses the number at the start of s, after any blanks,
+// as sort -n does. Anything that is not a number counts as zero.
+func leadingNumber(s string) float64 {
+	s = strings.TrimLeft(s, " \t")
+	i := 0
+	if i < len(s) && (s[i] == '-' || s[i] == '+') {
+		i++
+	}
+	i = skipDigits(s, i)
+	if i < len(s) && s[i] == '.' {
+		i = skipDigits(s, i+1)
+	}
+	f, err := strconv.ParseFloat(s[:i], 64)
+	if err != nil {
+		return 0
+	}
+	return f
+}
+
+func compareFloats(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// floatChars holds every byte strconv.ParseFloat may accept.
+const floatChars = "+-.0123456789_abcdefinptxyABCDEFINPTXY"
+
+// generalNumber parses the floating point number at the start of s,
+// accepting exponents, "inf" and "nan" as strconv does.
+func generalNumber(s string) (float64, bool) {
+	s = strings.TrimLeft(s, " \t")
+	// Try the longest plausible prefix first so "1.5e3kg" reads as 1500.
+	end := 0
+	for end < len(s) && strings.IndexByte(floatChars, s[end]) >= 0 {
+		end++
+	}
+	for ; end > 0; end-- {
+		if f, err := strconv.ParseFloat(s[:end], 64); err == nil {
+			return f, true
+		}
+	}
+	return 0, false
+}
+
+// compareGeneral orders general numbers, sorting anything that is not
+// a number first and NaN before every other number, like sort -g.
+func compareGeneral(a, b string) int {
+	fa, oka := generalNumber(a)
+	fb, okb := generalNumber(b)
+	switch {
+	case !oka || !okb:
+		return compareBools(oka, okb)
+	case math.IsNaN(fa) || math.IsNaN(fb):
+		return compareBools(!math.IsNaN(fa), !math.IsNaN(fb))
+	}
+	return compareFloats(fa, fb)
+}
+
+// compareBools orders false before true.
+func compareBools(a, b bool) int {
+	switch {
+	case a == b:
+		return 0
+	case !a:
+		return -1
+	}
+	return 1
+}
+
+// humanSize reads a size such as "512M", "1.5GiB" or "300k" as bytes.
+// Suffixes are powers of 1024; a value without a number counts as zero.
+func humanSize(s string) float64 {
+	s = strings.TrimLeft(s, " \t")
+	i := 0
+	if i < len(s) && (s[i] == '-' || s[i] == '+') {
+		i++
+	}
+	i = skipDigits(s, i)
+	if i < len(s) && s[i] == '.' {
+		i = skipDigits(s, i+1)
+	}
+	f, err := strconv.ParseFloat(s[:i], 64)
+	if err != nil {
+		return 0
+	}
+	if i < len(s) {
+		if p := strings.IndexByte("KMGTPEZY", upper(s[i])); p >= 0 {
+			f *= math.Pow(1024, float64(p+1))
+		}
+	}
+	return f
+}
+
+func upper(c byte) byte {
+	if c >= 'a' && c <= 'z' {
+		return c - 'a' + 'A'
+	}
+	return c
+}
+
+// compareNatural compares strings so that runs of digits are ordered
+// by their numeric value and everything else byte by byte.
+func compareNatural(a, b string) int {
+	for a != "" && b != "" {
+		da, db := isDigit(a[0]), isDigit(b[0])
+		if da && db {
+			na, nb := skipDigits(a, 0), skipDigits(b, 0)
+			if c := compareDigits(a[:na], b[:nb]); c != 0 {
+				return c
+			}
+			a, b = a[na:], b[nb:]
+			continue
+		}
+		if a[0] != b[0] {
+			if a[0] < b[0] {
+				return -1
+			}
+			return 1
+		}
+		a, b = a[1:], b[1:]
+	}
+	return compareInts(len(a), len(b))
+}
+
+// compareDigits compares two runs of decimal digits of any length by
+// value. Equal values with more leading zeros sort later.
+func compareDigits(a, b string) int {
+	ta, tb := strings.TrimLeft(a, "0"), strings.TrimLeft(b, "0")
+	if c := compareInts(len(ta), len(tb)); c != 0 {
+		return c
+	}
+	if c := strings.Compare(ta, tb); c != 0 {
+		return c
+	}
+	return compareInts(len(a), len(b))
+}
+
+func compareInts(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func isDigit(c byte) bool { return c >= '0' && c <= '9' }
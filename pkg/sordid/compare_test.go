@@ -0,0 +1,66 @@
+package sordid
+
+import (
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestCompareModes(t *testing.T) {
+	tests := []struct {
+		mode compareMode
+		in   []string
+		want []string
+	}{
+		{modeNumeric, []string{"10", "9", "-2", "x", "1.5"}, []string{"-2", "x", "1.5", "9", "10"}},
+		{modeGeneral, []string{"1e3", "20", "x", "-inf", "NaN", "2.5E-1"}, []string{"x", "NaN", "-inf", "2.5E-1", "20", "1e3"}},
+		{modeHuman, []string{"2G", "512M", "1.5K", "100", "1T"}, []string{"100", "1.5K", "512M", "2G", "1T"}},
+		{modeNatural, []string{"file10", "file2", "file1", "File3", "file02"}, []string{"File3", "file1", "file2", "file02", "file10"}},
+		{modeVersion, []string{"1.10.0", "1.9.0", "v1.9.1", "1.10.0-rc.1", "1.10.0-beta", "1.10.0-rc.2"}, []string{"1.9.0", "v1.9.1", "1.10.0-beta", "1.10.0-rc.1", "1.10.0-rc.2", "1.10.0"}},
+		{modeVersion, []string{"1:0.9", "2.0-1", "1.0~rc1", "1.0", "1.0-2", "1.0a"}, []string{"1.0~rc1", "1.0", "1.0-2", "1.0a", "2.0-1", "1:0.9"}},
+		{modeVersion, []string{"1.0.0", "1.0.0-alpha.beta", "1.0.0-alpha.1", "1.0.0-alpha"}, []string{"1.0.0-alpha", "1.0.0-alpha.1", "1.0.0-alpha.beta", "1.0.0"}},
+	}
+	for _, test := range tests {
+		got := append([]string(nil), test.in...)
+		sort.SliceStable(got, func(i, j int) bool { return compareKeys(test.mode, got[i], got[j]) < 0 })
+		if strings.Join(got, " ") != strings.Join(test.want, " ") {
+			t.Errorf("mode %d: expected %q, got %q", test.mode, test.want, got)
+		}
+	}
+}
+
+func TestParseMode(t *testing.T) {
+	for name, want := range modeNames {
+		if got, err := parseMode(name); err != nil || got != want {
+			t.Error("For", name, "expected", want, "got", got, err)
+		}
+	}
+	if _, err := parseMode("alphabetical"); err == nil {
+		t.Error("expected error for unknown mode")
+	}
+}
+
+func TestCollation(t *testing.T) {
+	tests := []struct {
+		opts keyOpts
+		in   []string
+		want []string
+	}{
+		{keyOpts{}, []string{"apple", "Zebra", "été", "zoo"}, []string{"Zebra", "apple", "zoo", "été"}},
+		{keyOpts{foldCase: true}, []string{"b", "B", "a", "A"}, []string{"a", "A", "b", "B"}},
+		{keyOpts{foldAccents: true}, []string{"f", "é", "e", "d"}, []string{"d", "é", "e", "f"}},
+		{keyOpts{dictionary: true}, []string{"b-c", "(a)", "ba"}, []string{"(a)", "ba", "b-c"}},
+		{keyOpts{mode: modeCollate}, []string{"zoo", "Zebra", "été", "apple"}, []string{"apple", "été", "Zebra", "zoo"}},
+		{keyOpts{mode: modeCollate, locale: "sv"}, []string{"ö", "z", "o"}, []string{"o", "z", "ö"}},
+		{keyOpts{mode: modeCollate, locale: "de"}, []string{"ö", "z", "o"}, []string{"o", "ö", "z"}},
+		{keyOpts{mode: modeCollate, foldCase: true}, []string{"B", "a", "b", "A"}, []string{"a", "A", "B", "b"}},
+	}
+	for _, test := range tests {
+		c := newComparer(nil, "", test.opts)
+		got := append([]string(nil), test.in...)
+		sort.SliceStable(got, func(i, j int) bool { return c.less(got[i], got[j]) })
+		if strings.Join(got, " ") != strings.Join(test.want, " ") {
+			t.Errorf("%+v: expected %q, got %q", test.opts, test.want, got)
+		}
+	}
+}
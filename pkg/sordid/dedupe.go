@@ -0,0 +1,115 @@
+package sordid
+
+import (
+	"errors"
+	"fmt"
+)
+
+// dedupeSpec controls how runs of adjacent lines with equal keys are
+// collapsed once sorted. Without keys, equal keys means equal lines.
+type dedupeSpec struct {
+	keepLast bool // keep the last line of each run instead of the first
+	count    bool // prefix each kept line with the length of its run, like uniq -c
+
+	onlyDups    bool // keep only runs of two or more lines, like uniq -d
+	onlySingles bool // keep only lines that have no duplicates, like uniq -u
+}
+
+// parseKeep reads which of a run of equal lines to keep: "first" or "last".
+func parseKeep(s string) (bool, error) {
+	switch s {
+	case "first":
+		return false, nil
+	case "last":
+		return true, nil
+	}
+	return false, errors.New("keep must be first or last, not " + s)
+}
+
+// lineSink receives sorted lines on their way to the output.
+type lineSink interface {
+	write(line string)
+	flush() error
+}
+
+// itemSink is a lineSink that also takes items whole, so that a filter
+// dropping an item drops its continuation lines with it.
+type itemSink interface {
+	lineSink
+	add(item []string)
+}
+
+// addItem passes item to out, whole where out takes items.
+func addItem(out lineSink, item []string) {
+	if s, ok := out.(itemSink); ok {
+		s.add(item)
+		return
+	}
+	for _, line := range item {
+		out.write(line)
+	}
+}
+
+// sliceSink collects lines in memory.
+type sliceSink struct {
+	lines []string
+}
+
+func (s *sliceSink) write(line string) { s.lines = append(s.lines, line) }
+func (s *sliceSink) flush() error      { return nil }
+
+// deduper is a lineSink that collapses runs of equal items before
+// passing them on. An item is a line plus any continuation lines; it
+// is compared by its first line only.
+type deduper struct {
+	spec *dedupeSpec
+	cmp  *comparer
+	out  lineSink
+
+	first, last []string
+	count       int
+}
+
+func newDeduper(spec *dedupeSpec, cmp *comparer, out lineSink) *deduper {
+	return &deduper{spec: spec, cmp: cmp, out: out}
+}
+
+func (d *deduper) write(line string) { d.add([]string{line}) }
+
+// add takes the next item in sorted order.
+func (d *deduper) add(item []string) {
+	if d.count > 0 && d.cmp.compare(d.first[0], item[0]) == 0 {
+		d.last = item
+		d.count++
+		return
+	}
+	d.emit()
+	d.first, d.last, d.count = item, item, 1
+}
+
+// emit writes out the current run, if it passes the filters.
+func (d *deduper) emit() {
+	switch {
+	case d.count == 0:
+		return
+	case d.spec.onlyDups && d.count < 2:
+		return
+	case d.spec.onlySingles && d.count > 1:
+		return
+	}
+
+	item := d.first
+	if d.spec.keepLast {
+		item = d.last
+	}
+	if d.spec.count {
+		item = append([]string{fmt.Sprintf("%7d %s", d.count, item[0])}, item[1:]...)
+	}
+	addItem(d.out, item)
+}
+
+func (d *deduper) flush() error {
+	d.emit()
+	d.count = 0
+	return d.out.flush()
+}
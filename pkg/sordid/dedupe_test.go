@@ -0,0 +1,57 @@
+package sordid
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDedupe(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sordid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	in := "b 2\na 1\nc 3\nb 1\na 1\nb 3\n"
+	tests := []struct {
+		key  string
+		spec dedupeSpec
+		want string
+	}{
+		{"", dedupeSpec{}, "a 1\nb 1\nb 2\nb 3\nc 3\n"},
+		{"1,1", dedupeSpec{}, "a 1\nb 2\nc 3\n"},
+		{"1,1", dedupeSpec{keepLast: true}, "a 1\nb 3\nc 3\n"},
+		{"1,1", dedupeSpec{count: true}, "      2 a 1\n      3 b 2\n      1 c 3\n"},
+		{"", dedupeSpec{onlyDups: true}, "a 1\n"},
+		{"1,1", dedupeSpec{onlySingles: true}, "c 3\n"},
+	}
+	for _, test := range tests {
+		var keys keyList
+		if test.key != "" {
+			if err := keys.Set(test.key); err != nil {
+				t.Fatal(err)
+			}
+		}
+		spec := test.spec
+		// A tiny memory limit takes the streaming merge, no limit the in-memory path.
+		for _, limit := range []int64{1, 0} {
+			path := filepath.Join(dir, "input")
+			if err := ioutil.WriteFile(path, []byte(in), 0644); err != nil {
+				t.Fatal(err)
+			}
+			o := &options{cmp: newComparer(keys, "", keyOpts{}), memLimit: limit, tmpDir: dir, dedupe: &spec}
+			if err := sortFile(path, o); err != nil {
+				t.Fatal(err)
+			}
+			got, err := ioutil.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != test.want {
+				t.Errorf("key %q %+v limit %d: expected %q, got %q", test.key, test.spec, limit, test.want, got)
+			}
+		}
+	}
+}
@@ -0,0 +1,230 @@
+package sordid
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+)
+
+// diffContext is the number of unchanged lines shown around each change.
+const diffContext = 3
+
+// diffOp is one step of an edit script: a line kept (' '), deleted
+// from a ('-') or inserted from b ('+'). a and b index the line in
+// the respective input.
+type diffOp struct {
+	kind byte
+	a, b int
+}
+
+// diffMaxCost bounds the edits the search for a middle snake looks
+// through. Past it, a stretch of lines is given up on as deleted and
+// inserted whole, as a shuffled file would be anyway; this keeps diffs
+// of long, badly unsorted files from taking quadratic time.
+const diffMaxCost = 1 << 12
+
+// diffLines returns an edit script turning a into b, found with the
+// linear space refinement of Myers' O(ND) algorithm. The script is the
+// shortest unless a stretch needs more than diffMaxCost edits.
+func diffLines(a, b []string) []diffOp {
+	ids := make(map[string]int)
+	intern := func(lines []string) []int {
+		out := make([]int, len(lines))
+		for i, line := range lines {
+			id, ok := ids[line]
+			if !ok {
+				id = len(ids)
+				ids[line] = id
+			}
+			out[i] = id
+		}
+		return out
+	}
+	d := &differ{a: intern(a), b: intern(b)}
+	size := 2*(len(a)+len(b)) + 3
+	d.vf, d.vb = make([]int, size), make([]int, size)
+	d.compare(0, len(a), 0, len(b))
+	return d.ops
+}
+
+// differ holds the lines being compared, as ids equal for equal
+// lines, and the script found so far.
+type differ struct {
+	a, b   []int
+	vf, vb []int // furthest x reached on each diagonal, forwards and backwards
+	ops    []diffOp
+}
+
+// compare appends the script turning a[aLo:aHi] into b[bLo:bHi].
+func (d *differ) compare(aLo, aHi, bLo, bHi int) {
+	for aLo < aHi && bLo < bHi && d.a[aLo] == d.b[bLo] {
+		d.ops = append(d.ops, diffOp{' ', aLo, bLo})
+		aLo++
+		bLo++
+	}
+	suffix := 0
+	for aLo < aHi-suffix && bLo < bHi-suffix && d.a[aHi-suffix-1] == d.b[bHi-suffix-1] {
+		suffix++
+	}
+	aHi, bHi = aHi-suffix, bHi-suffix
+
+	switch x0, y0, x1, y1, ok := d.middleSnake(aLo, aHi, bLo, bHi); {
+	case aLo == aHi || bLo == bHi || !ok:
+		for x := aLo; x < aHi; x++ {
+			d.ops = append(d.ops, diffOp{'-', x, bLo})
+		}
+		for y := bLo; y < bHi; y++ {
+			d.ops = append(d.ops, diffOp{'+', aHi, y})
+		}
+	default:
+		d.compare(aLo, x0, bLo, y0)
+		for x, y := x0, y0; x < x1; x, y = x+1, y+1 {
+			d.ops = append(d.ops, diffOp{' ', x, y})
+		}
+		d.compare(x1, aHi, y1, bHi)
+	}
+
+	for i := 0; i < suffix; i++ {
+		d.ops = append(d.ops, diffOp{' ', aHi + i, bHi + i})
+	}
+}
+
+// middleSnake finds the run of matching lines in the middle of a
+// shortest script turning a[aLo:aHi] into b[bLo:bHi], which both hold
+// lines, searching from both ends at once. It reports false once that
+// needs more than diffMaxCost edits from either end.
+func (d *differ) middleSnake(aLo, aHi, bLo, bHi int) (x0, y0, x1, y1 int, ok bool) {
+	n, m := aHi-aLo, bHi-bLo
+	if n == 0 || m == 0 {
+		return 0, 0, 0, 0, false
+	}
+	a, b := d.a[aLo:aHi], d.b[bLo:bHi]
+	delta := n - m
+	odd := delta%2 != 0
+	// Diagonal k holds the points with x-y == k; vb is indexed by
+	// the diagonal counted from the far end, and holds how far back
+	// from it each reaches.
+	off := n + m + 1
+	vf, vb := d.vf, d.vb
+	vf[off+1], vb[off+1] = 0, 0
+
+	for e := 0; e <= (n+m+1)/2 && e <= diffMaxCost; e++ {
+		for k := -e; k <= e; k += 2 {
+			var x int
+			if k == -e || (k != e && vf[off+k-1] < vf[off+k+1]) {
+				x = vf[off+k+1]
+			} else {
+				x = vf[off+k-1] + 1
+			}
+			y := x - k
+			sx, sy := x, y
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			vf[off+k] = x
+			if kb := delta - k; odd && kb >= -(e-1) && kb <= e-1 && x+vb[off+kb] >= n {
+				return aLo + sx, bLo + sy, aLo + x, bLo + y, true
+			}
+		}
+		for k := -e; k <= e; k += 2 {
+			var x int
+			if k == -e || (k != e && vb[off+k-1] < vb[off+k+1]) {
+				x = vb[off+k+1]
+			} else {
+				x = vb[off+k-1] + 1
+			}
+			y := x - k
+			sx, sy := x, y
+			for x < n && y < m && a[n-x-1] == b[m-y-1] {
+				x++
+				y++
+			}
+			vb[off+k] = x
+			if kf := delta - k; !odd && kf >= -e && kf <= e && x+vf[off+kf] >= n {
+				return aLo + n - x, bLo + m - y, aLo + n - sx, bLo + m - sy, true
+			}
+		}
+	}
+	return 0, 0, 0, 0, false
+}
+
+// unifiedDiff writes the changes from a to b as a unified diff
+// between oldName and newName. Nothing is written when they match.
+func unifiedDiff(w io.Writer, oldName, newName string, a, b []string) error {
+	ops := diffLines(a, b)
+
+	bw := bufio.NewWriter(w)
+	header := false
+	for i := 0; i < len(ops); {
+		if ops[i].kind == ' ' {
+			i++
+			continue
+		}
+
+		// Extend the hunk while changes are close enough that
+		// their context would touch.
+		start := i - diffContext
+		if start < 0 {
+			start = 0
+		}
+		end := i
+		for j := i; j < len(ops); j++ {
+			if ops[j].kind != ' ' {
+				end = j + 1
+			} else if j-end >= 2*diffContext {
+				break
+			}
+		}
+		stop := end + diffContext
+		if stop > len(ops) {
+			stop = len(ops)
+		}
+
+		if !header {
+			fmt.Fprintf(bw, "--- %s\n+++ %s\n", oldName, newName)
+			header = true
+		}
+		writeHunk(bw, ops[start:stop], a, b)
+		i = stop
+	}
+	return bw.Flush()
+}
+
+// writeHunk writes one "@@" section covering ops.
+func writeHunk(w *bufio.Writer, ops []diffOp, a, b []string) {
+	aStart, bStart := ops[0].a, ops[0].b
+	var aLen, bLen int
+	for _, op := range ops {
+		if op.kind != '+' {
+			aLen++
+		}
+		if op.kind != '-' {
+			bLen++
+		}
+	}
+
+	fmt.Fprintf(w, "@@ -%s +%s @@\n", hunkRange(aStart, aLen), hunkRange(bStart, bLen))
+	for _, op := range ops {
+		switch op.kind {
+		case '+':
+			fmt.Fprintf(w, "+%s\n", b[op.b])
+		case '-':
+			fmt.Fprintf(w, "-%s\n", a[op.a])
+		default:
+			fmt.Fprintf(w, " %s\n", a[op.a])
+		}
+	}
+}
+
+// hunkRange formats a zero-based start and a length the way diff -u
+// does: one-based, with an empty range naming the line before it.
+func hunkRange(start, length int) string {
+	switch length {
+	case 0:
+		return fmt.Sprintf("%d,0", start)
+	case 1:
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, length)
+}
@@ -0,0 +1,113 @@
+package sordid
+
+import (
+	"bytes"
+	"math/rand"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want string
+	}{
+		{"a b c", "a b c", ""},
+		{
+			"b a c", "a b c",
+			"--- x\n+++ y\n@@ -1,3 +1,3 @@\n-b\n a\n+b\n c\n",
+		},
+		{
+			"1 2 3 4 5 6 7 8 9 10 11 12 13 14 15 16", "1 2 3 4 5 6 7 8 9 10 11 12 13 14 15 16 17",
+			"--- x\n+++ y\n@@ -14,3 +14,4 @@\n 14\n 15\n 16\n+17\n",
+		},
+		{
+			"1 x 2 3 4 5 6 7 8 9 10 y", "1 2 3 4 5 6 7 8 9 10",
+			"--- x\n+++ y\n@@ -1,5 +1,4 @@\n 1\n-x\n 2\n 3\n 4\n@@ -9,4 +8,3 @@\n 8\n 9\n 10\n-y\n",
+		},
+		{"", "a", "--- x\n+++ y\n@@ -0,0 +1 @@\n+a\n"},
+	}
+	for _, test := range tests {
+		var buf bytes.Buffer
+		if err := unifiedDiff(&buf, "x", "y", strings.Fields(test.a), strings.Fields(test.b)); err != nil {
+			t.Fatal(err)
+		}
+		if buf.String() != test.want {
+			t.Errorf("diff %q -> %q: expected\n%s\ngot\n%s", test.a, test.b, test.want, buf.String())
+		}
+	}
+}
+
+func TestDiffLinesScripts(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	random := func(n int) []string {
+		lines := make([]string, n)
+		for i := range lines {
+			lines[i] = string(rune('a' + r.Intn(4)))
+		}
+		return lines
+	}
+	shuffled := make([]string, 20000)
+	for i := range shuffled {
+		shuffled[i] = strconv.Itoa(i)
+	}
+	sorted := append([]string(nil), shuffled...)
+	r.Shuffle(len(shuffled), func(i, j int) { shuffled[i], shuffled[j] = shuffled[j], shuffled[i] })
+
+	type pair struct{ a, b []string }
+	pairs := []pair{{shuffled, sorted}}
+	for i := 0; i < 300; i++ {
+		pairs = append(pairs, pair{random(r.Intn(30)), random(r.Intn(30))})
+	}
+	for _, p := range pairs {
+		ops := diffLines(p.a, p.b)
+		var got []string
+		x, y, edits := 0, 0, 0
+		for _, op := range ops {
+			if op.a != x || op.b != y {
+				t.Fatalf("%q -> %q: op %c at %d,%d, expected %d,%d", p.a, p.b, op.kind, op.a, op.b, x, y)
+			}
+			switch op.kind {
+			case ' ':
+				if p.a[x] != p.b[y] {
+					t.Fatalf("%q -> %q: kept %q as %q", p.a, p.b, p.a[x], p.b[y])
+				}
+				got = append(got, p.a[x])
+				x, y = x+1, y+1
+			case '-':
+				x, edits = x+1, edits+1
+			case '+':
+				got = append(got, p.b[y])
+				y, edits = y+1, edits+1
+			}
+		}
+		if x != len(p.a) || strings.Join(got, "\n") != strings.Join(p.b, "\n") {
+			t.Fatalf("%q -> %q: script gives %q", p.a, p.b, got)
+		}
+		if len(p.a) < 100 {
+			if want := len(p.a) + len(p.b) - 2*lcsLength(p.a, p.b); edits != want {
+				t.Errorf("%q -> %q: %d edits, expected %d", p.a, p.b, edits, want)
+			}
+		}
+	}
+}
+
+// lcsLength returns the length of the longest common subsequence.
+func lcsLength(a, b []string) int {
+	prev, cur := make([]int, len(b)+1), make([]int, len(b)+1)
+	for i := range a {
+		for j := range b {
+			switch {
+			case a[i] == b[j]:
+				cur[j+1] = prev[j] + 1
+			case prev[j+1] > cur[j]:
+				cur[j+1] = prev[j+1]
+			default:
+				cur[j+1] = cur[j]
+			}
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
@@ -0,0 +1,354 @@
+package sordid
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// documentFormat is the syntax of a structured document.
+type documentFormat int
+
+const (
+	documentJSON documentFormat = iota
+	documentYAML
+)
+
+// documentFormats maps format names onto document formats.
+var documentFormats = map[string]documentFormat{
+	"json": documentJSON,
+	"yaml": documentYAML,
+}
+
+// documentSpec canonicalizes JSON or YAML documents: the keys of every
+// object are put in byte order, and the arrays matched by a rule are
+// sorted by the rule's field.
+type documentSpec struct {
+	format documentFormat
+	arrays []arrayRule
+}
+
+// arrayRule selects arrays by path, where "*" matches any key or
+// index, and orders their elements by the value at field within each
+// element, or by the element itself when field is empty.
+type arrayRule struct {
+	path  []string
+	field []string
+}
+
+// parseArrayRule reads a rule such as ".users=.name" or ".tags".
+func parseArrayRule(s string) (arrayRule, error) {
+	path, field := s, ""
+	if i := strings.IndexByte(s, '='); i >= 0 {
+		path, field = s[:i], s[i+1:]
+	}
+	if !strings.HasPrefix(path, ".") {
+		return arrayRule{}, errors.New("invalid array path: " + s)
+	}
+	return arrayRule{path: splitPath(path), field: splitPath(field)}, nil
+}
+
+// arrayRules collects array rules given one at a time.
+type arrayRules []arrayRule
+
+func (l *arrayRules) String() string { return strconv.Itoa(len(*l)) + " arrays" }
+
+func (l *arrayRules) Set(s string) error {
+	r, err := parseArrayRule(s)
+	if err != nil {
+		return err
+	}
+	*l = append(*l, r)
+	return nil
+}
+
+// sorts returns the rule for the array at path, or nil.
+func (d *documentSpec) sorts(path []string) *arrayRule {
+rules:
+	for i := range d.arrays {
+		r := &d.arrays[i]
+		if len(r.path) != len(path) {
+			continue
+		}
+		for j, p := range r.path {
+			if p != "*" && p != path[j] {
+				continue rules
+			}
+		}
+		return r
+	}
+	return nil
+}
+
+// readDocument reads every input whole, one after the other.
+func readDocument(inputs []*input) ([]byte, error) {
+	var data []byte
+	for _, in := range inputs {
+		b, err := ioutil.ReadAll(in)
+		if err != nil {
+			return nil, err
+		}
+		data = append(data, b...)
+	}
+	return data, nil
+}
+
+// sortDocument returns the canonical form of the documents in data.
+func (d *documentSpec) sortDocument(data []byte, cmp *comparer) ([]byte, error) {
+	if d.format == documentYAML {
+		return d.sortYAML(data, cmp)
+	}
+	return d.sortJSON(data, cmp)
+}
+
+// sortJSON canonicalizes a stream of JSON values. The output is
+// indented like the input, or compact if the input has no indented
+// lines.
+func (d *documentSpec) sortJSON(data []byte, cmp *comparer) ([]byte, error) {
+	var out bytes.Buffer
+	enc := json.NewEncoder(&out)
+	enc.SetEscapeHTML(false)
+	if indent := detectIndent(data); indent != "" {
+		enc.SetIndent("", indent)
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	for {
+		var v interface{}
+		err := dec.Decode(&v)
+		if err == io.EOF {
+			return out.Bytes(), nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := d.sortJSONArrays(v, nil, cmp); err != nil {
+			return nil, err
+		}
+		// Maps are written with their keys in order.
+		if err := enc.Encode(v); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// sortJSONArrays sorts the arrays within v that match a rule.
+func (d *documentSpec) sortJSONArrays(v interface{}, path []string, cmp *comparer) error {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, e := range t {
+			if err := d.sortJSONArrays(e, append(path, k), cmp); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		for i, e := range t {
+			if err := d.sortJSONArrays(e, append(path, strconv.Itoa(i)), cmp); err != nil {
+				return err
+			}
+		}
+		r := d.sorts(path)
+		if r == nil {
+			return nil
+		}
+		keys := make([]string, len(t))
+		for i, e := range t {
+			var err error
+			if keys[i], err = jsonText(jsonLookup(e, r.field)); err != nil {
+				return err
+			}
+		}
+		sortByKeys(len(t), keys, cmp, func(i, j int) {
+			t[i], t[j] = t[j], t[i]
+		})
+	}
+	return nil
+}
+
+// sortByKeys stably sorts n elements by their keys, calling swap for
+// each exchange so that the caller's elements follow.
+func sortByKeys(n int, keys []string, cmp *comparer, swap func(i, j int)) {
+	sort.Stable(&keyedSlice{keys: keys, cmp: cmp, swap: swap})
+}
+
+type keyedSlice struct {
+	keys []string
+	cmp  *comparer
+	swap func(i, j int)
+}
+
+func (s *keyedSlice) Len() int           { return len(s.keys) }
+func (s *keyedSlice) Less(i, j int) bool { return s.cmp.less(s.keys[i], s.keys[j]) }
+func (s *keyedSlice) Swap(i, j int) {
+	s.keys[i], s.keys[j] = s.keys[j], s.keys[i]
+	s.swap(i, j)
+}
+
+// sortYAML canonicalizes a stream of YAML documents. Comments travel
+// with the keys and elements they belong to, and the indentation of
+// the input is kept.
+func (d *documentSpec) sortYAML(data []byte, cmp *comparer) ([]byte, error) {
+	var out bytes.Buffer
+	enc := yaml.NewEncoder(&out)
+	indent := len(detectIndent(data))
+	if indent < 2 {
+		indent = 2
+	}
+	enc.SetIndent(indent)
+
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	for {
+		var doc yaml.Node
+		err := dec.Decode(&doc)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		d.sortYAMLNode(&doc, nil, cmp)
+		if err := checkAnchors(&doc, map[*yaml.Node]bool{}); err != nil {
+			return nil, err
+		}
+		if err := enc.Encode(&doc); err != nil {
+			return nil, err
+		}
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// sortYAMLNode puts the keys of every mapping under n in order and
+// sorts the sequences that match a rule.
+func (d *documentSpec) sortYAMLNode(n *yaml.Node, path []string, cmp *comparer) {
+	switch n.Kind {
+	case yaml.DocumentNode:
+		for _, c := range n.Content {
+			d.sortYAMLNode(c, path, cmp)
+		}
+	case yaml.MappingNode:
+		pairs := n.Content
+		for i := 0; i+1 < len(pairs); i += 2 {
+			d.sortYAMLNode(pairs[i+1], append(path, pairs[i].Value), cmp)
+		}
+		sort.Stable(yamlPairs(pairs))
+	case yaml.SequenceNode:
+		for i, c := range n.Content {
+			d.sortYAMLNode(c, append(path, strconv.Itoa(i)), cmp)
+		}
+		r := d.sorts(path)
+		if r == nil {
+			return
+		}
+		keys := make([]string, len(n.Content))
+		for i, c := range n.Content {
+			keys[i] = yamlText(yamlLookup(c, r.field))
+		}
+		sortByKeys(len(keys), keys, cmp, func(i, j int) {
+			n.Content[i], n.Content[j] = n.Content[j], n.Content[i]
+		})
+	}
+}
+
+// yamlPairs orders the key and value nodes of a mapping by key.
+type yamlPairs []*yaml.Node
+
+func (p yamlPairs) Len() int           { return len(p) / 2 }
+func (p yamlPairs) Less(i, j int) bool { return p[2*i].Value < p[2*j].Value }
+func (p yamlPairs) Swap(i, j int) {
+	p[2*i], p[2*j] = p[2*j], p[2*i]
+	p[2*i+1], p[2*j+1] = p[2*j+1], p[2*i+1]
+}
+
+// yamlLookup follows path into a node, returning nil where it leads
+// nowhere.
+func yamlLookup(n *yaml.Node, path []string) *yaml.Node {
+	for _, part := range path {
+		switch n.Kind {
+		case yaml.MappingNode:
+			var next *yaml.Node
+			for i := 0; i+1 < len(n.Content); i += 2 {
+				if n.Content[i].Value == part {
+					next = n.Content[i+1]
+				}
+			}
+			n = next
+		case yaml.SequenceNode:
+			i, err := strconv.Atoi(part)
+			if err != nil || i < 0 || i >= len(n.Content) {
+				return nil
+			}
+			n = n.Content[i]
+		default:
+			return nil
+		}
+		if n == nil {
+			return nil
+		}
+	}
+	return n
+}
+
+// yamlText renders a node as a sort key: scalars give their value,
+// anything else an empty key.
+func yamlText(n *yaml.Node) string {
+	if n == nil {
+		return ""
+	}
+	if n.Kind == yaml.AliasNode {
+		n = n.Alias
+	}
+	if n.Kind != yaml.ScalarNode {
+		return ""
+	}
+	return n.Value
+}
+
+// checkAnchors fails if sorting has moved an alias ahead of the anchor
+// it refers to, which would no longer parse.
+func checkAnchors(n *yaml.Node, seen map[*yaml.Node]bool) error {
+	if n.Kind == yaml.AliasNode && !seen[n.Alias] {
+		return fmt.Errorf("line %d: sorting moves alias *%s before its anchor", n.Line, n.Value)
+	}
+	if n.Anchor != "" {
+		seen[n] = true
+	}
+	for _, c := range n.Content {
+		if err := checkAnchors(c, seen); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// detectIndent returns the leading blanks of the first indented line
+// in data, taken as one level of indentation.
+func detectIndent(data []byte) string {
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		trimmed := bytes.TrimLeft(line, " \t")
+		if len(trimmed) > 0 && len(trimmed) < len(line) {
+			return string(line[:len(line)-len(trimmed)])
+		}
+	}
+	return ""
+}
+
+// documentLines splits a document into lines for diffs.
+func documentLines(data []byte) []string {
+	s := strings.TrimSuffix(string(data), "\n")
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, "\n")
+}
@@ -0,0 +1,64 @@
+package sordid
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSortDocument(t *testing.T) {
+	tests := []struct {
+		format string
+		arrays []string
+		in     string
+		want   string
+	}{
+		{
+			"json", nil,
+			`{"b":1,"a":{"d":[3,1,2],"c":"<x>"}}`,
+			`{"a":{"c":"<x>","d":[3,1,2]},"b":1}` + "\n",
+		},
+		{
+			"json", []string{".users=.name", ".users.*.tags"},
+			"{\n\t\"users\": [{\"name\": \"bo\", \"tags\": [\"z\", \"a\"]}, {\"name\": \"al\", \"id\": 1.50}]\n}\n",
+			"{\n\t\"users\": [\n\t\t{\n\t\t\t\"id\": 1.50,\n\t\t\t\"name\": \"al\"\n\t\t},\n\t\t{\n\t\t\t\"name\": \"bo\",\n\t\t\t\"tags\": [\n\t\t\t\t\"a\",\n\t\t\t\t\"z\"\n\t\t\t]\n\t\t}\n\t]\n}\n",
+		},
+		{
+			"yaml", []string{".hosts=.name"},
+			"# config\n\nzone: b # the zone\nhosts:\n  # primary\n  - name: web2\n    port: 80\n  - name: web1\n    port: 81\nalpha: 1\n",
+			"# config\n\nalpha: 1\nhosts:\n  - name: web1\n    port: 81\n  # primary\n  - name: web2\n    port: 80\nzone: b # the zone\n",
+		},
+		{
+			"yaml", nil,
+			"b: 1\na: 2\n---\nd: 1\nc: 2\n",
+			"a: 2\nb: 1\n---\nc: 2\nd: 1\n",
+		},
+	}
+	for _, test := range tests {
+		opts := []Option{Format(test.format)}
+		for _, a := range test.arrays {
+			opts = append(opts, Array(a))
+		}
+		s, err := New(opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got strings.Builder
+		if err := s.Sort(&got, strings.NewReader(test.in)); err != nil {
+			t.Fatal(err)
+		}
+		if got.String() != test.want {
+			t.Errorf("for %q expected\n%s\ngot\n%s", test.in, test.want, got.String())
+		}
+	}
+}
+
+func TestSortDocumentAliases(t *testing.T) {
+	s, err := New(Format("yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out strings.Builder
+	if err := s.Sort(&out, strings.NewReader("z: &x 1\na: *x\n")); err == nil {
+		t.Errorf("expected an error for an alias moved before its anchor, got %q", out.String())
+	}
+}
@@ -0,0 +1,466 @@
+package sordid
+
+import (
+	"bufio"
+	"container/heap"
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// lineOverhead approximates the memory held by a string header
+// and its slot in the backing slice, in addition to the line bytes.
+const lineOverhead = 32
+
+// options carries the settings for a single sort.
+type options struct {
+	cmp      *comparer
+	memLimit int64  // bytes of lines held in memory; zero for no limit
+	tmpDir   string // where sorted runs are spilled
+	nul      bool   // records end in NUL rather than newline
+	workers  int    // goroutines sorting in parallel; one or less sorts on the caller's
+
+	region   *regionSpec // sort only marked regions; nil sorts the whole file
+	dedupe   *dedupeSpec // collapse runs of equal lines; nil keeps them all
+	collapse bool        // drop lines whose CIDR block an earlier line's holds
+	pick     *pickSpec   // output top, bottom, shuffled or sampled lines; nil sorts them all
+
+	records *recordSpec // sort CSV, TSV or JSON Lines records; nil sorts lines
+	blocks  *blockSpec  // sort multi-line records as units; nil sorts lines
+
+	document *documentSpec // canonicalize JSON or YAML; nil sorts lines
+	preset   *presetSpec   // sort a file format with groups and comments; nil sorts lines
+}
+
+// wholeFile reports whether the options need the entire file in
+// memory rather than sorted chunks.
+func (o *options) wholeFile() bool {
+	return o.region != nil || o.records != nil || o.blocks != nil || o.document != nil || o.preset != nil
+}
+
+// sortLines sorts a file's lines held in memory according to o.
+func sortLines(lines []string, o *options) ([]string, error) {
+	var sorted []string
+	switch {
+	case o.region != nil:
+		var err error
+		if sorted, err = sortRegions(lines, o); err != nil {
+			return nil, err
+		}
+	case o.blocks != nil:
+		sorted = sortBlocks(lines, o)
+	case o.preset != nil:
+		var err error
+		if sorted, err = o.preset.sortLines(lines); err != nil {
+			return nil, err
+		}
+	case o.pick != nil:
+		sorted = o.pick.pickLines(lines, o.cmp)
+	default:
+		sorted = append([]string(nil), lines...)
+		sortParallel(sorted, o.cmp, o.workers)
+		sorted = dedupeLines(sorted, o)
+	}
+	return sorted, o.timeErr()
+}
+
+// filtered reports whether sorted lines pass through filters that
+// may drop or change them.
+func (o *options) filtered() bool {
+	return o.dedupe != nil || o.collapse
+}
+
+// filter wraps out in the filters the options ask for.
+func (o *options) filter(out lineSink) lineSink {
+	if o.dedupe != nil {
+		out = newDeduper(o.dedupe, o.cmp, out)
+	}
+	if o.collapse {
+		out = &collapser{key: o.cmp.cidrKey(), sep: o.cmp.sep, out: out}
+	}
+	return out
+}
+
+// dedupeLines applies the dedupe and collapse options to lines
+// already sorted.
+func dedupeLines(lines []string, o *options) []string {
+	if !o.filtered() {
+		return lines
+	}
+	out := &sliceSink{}
+	f := o.filter(out)
+	for _, line := range lines {
+		f.write(line)
+	}
+	f.flush() //nolint:errcheck
+	return out.lines
+}
+
+// chunker reads lines from its inputs in turn and hands back sorted
+// chunks whose estimated memory footprint stays below limit. A limit
+// of zero disables chunking and reads everything at once.
+type chunker struct {
+	inputs  []*input
+	cmp     *comparer
+	limit   int64
+	workers int
+}
+
+func newChunker(inputs []*input, o *options) *chunker {
+	return &chunker{inputs: inputs, cmp: o.cmp, limit: o.memLimit, workers: o.workers}
+}
+
+func (c *chunker) sort(lines []string) error {
+	sortParallel(lines, c.cmp, c.workers)
+	return c.cmp.err()
+}
+
+// next returns the next sorted chunk, or io.EOF once the inputs are drained.
+func (c *chunker) next() ([]string, error) {
+	var lines []string
+	var size int64
+	for len(c.inputs) > 0 {
+		s := c.inputs[0].scanner
+		for s.Scan() {
+			line := s.Text()
+			lines = append(lines, line)
+			size += int64(len(line)) + lineOverhead
+			if c.limit > 0 && size >= c.limit {
+				return lines, c.sort(lines)
+			}
+		}
+		if err := s.Err(); err != nil {
+			return nil, err
+		}
+		c.inputs = c.inputs[1:]
+	}
+
+	if lines == nil {
+		return nil, io.EOF
+	}
+	return lines, c.sort(lines)
+}
+
+// writeFunc hands a sorted result to its destination by calling fill
+// with the writer to produce it on.
+type writeFunc func(fill func(w io.Writer) error) error
+
+// sortFile sorts the file at path in place.
+func sortFile(path string, o *options) error {
+	in, err := openInput(path, o.nul)
+	if err != nil {
+		return err
+	}
+	defer in.Close() //nolint:errcheck
+
+	return sortInputs([]*input{in}, o, in.format, func(fill func(w io.Writer) error) error {
+		in.Close() //nolint:errcheck
+		return atomicWrite(path, fill)
+	})
+}
+
+// sortInputs sorts the lines of all the inputs together and writes
+// them out in format. Inputs that fit under the memory limit are
+// sorted in memory; anything larger is split into sorted runs on
+// disk which are then merged into the output. Every input has been
+// read by the time write is called.
+func sortInputs(inputs []*input, o *options, format *textFormat, write writeFunc) error {
+	if o.document != nil {
+		data, err := readDocument(inputs)
+		if err != nil {
+			return err
+		}
+		sorted, err := o.document.sortDocument(data, o.cmp)
+		if err != nil {
+			return err
+		}
+		if err := o.timeErr(); err != nil {
+			return err
+		}
+		return write(func(w io.Writer) error {
+			_, err := w.Write(sorted)
+			return err
+		})
+	}
+	if o.records != nil {
+		header, recs, err := readRecords(inputs, o.records, format)
+		if err != nil {
+			return err
+		}
+		o.records.sortRecords(recs)
+		if err := o.timeErr(); err != nil {
+			return err
+		}
+		return write(o.records.recordsFiller(header, recs, format))
+	}
+	if o.pick != nil {
+		lines, err := pickInputs(inputs, o)
+		if err != nil {
+			return err
+		}
+		return write(linesFiller(lines, format))
+	}
+	if o.wholeFile() {
+		var lines []string
+		for _, in := range inputs {
+			for in.scanner.Scan() {
+				lines = append(lines, in.scanner.Text())
+			}
+			if err := in.scanner.Err(); err != nil {
+				return err
+			}
+		}
+		lines, err := sortLines(lines, o)
+		if err != nil {
+			return err
+		}
+		return write(linesFiller(lines, format))
+	}
+
+	c := newChunker(inputs, o)
+	first, err := c.next()
+	if err == io.EOF {
+		return write(linesFiller(nil, format))
+	}
+	if err != nil {
+		return err
+	}
+
+	second, err := c.next()
+	if err == io.EOF {
+		return write(linesFiller(dedupeLines(first, o), format))
+	}
+	if err != nil {
+		return err
+	}
+
+	var runs []string
+	defer func() {
+		for _, r := range runs {
+			os.Remove(r) //nolint:errcheck
+		}
+	}()
+
+	for _, chunk := range [][]string{first, second} {
+		r, err := writeRun(chunk, o.tmpDir)
+		if err != nil {
+			return err
+		}
+		runs = append(runs, r)
+	}
+	first, second = nil, nil
+
+	for {
+		chunk, err := c.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		r, err := writeRun(chunk, o.tmpDir)
+		if err != nil {
+			return err
+		}
+		runs = append(runs, r)
+	}
+
+	readers := make([]*runReader, 0, len(runs))
+	for _, name := range runs {
+		f, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		defer f.Close() //nolint:errcheck
+		readers = append(readers, newRunFileReader(f))
+	}
+	return write(mergeFiller(readers, o, format))
+}
+
+// mergeInputs merges inputs that are each sorted already, streaming
+// them into the output without sorting them again.
+func mergeInputs(inputs []*input, o *options, format *textFormat, write writeFunc) error {
+	if o.pick != nil {
+		// Picking reads every line anyway, sorted or not.
+		return sortInputs(inputs, o, format, write)
+	}
+	readers := make([]*runReader, 0, len(inputs))
+	for _, in := range inputs {
+		readers = append(readers, newRunScanner(in.scanner))
+	}
+	return write(mergeFiller(readers, o, format))
+}
+
+// linesFiller writes out lines that are already in order.
+func linesFiller(lines []string, format *textFormat) func(w io.Writer) error {
+	return func(w io.Writer) error {
+		lw := newLineWriter(w, format)
+		for _, line := range lines {
+			lw.write(line)
+		}
+		return lw.flush()
+	}
+}
+
+// mergeFiller writes out the merge of the sorted runs.
+func mergeFiller(runs []*runReader, o *options, format *textFormat) func(w io.Writer) error {
+	return func(w io.Writer) error {
+		sink := o.filter(newLineWriter(w, format))
+		if err := mergeRuns(runs, sink, o.cmp); err != nil {
+			return err
+		}
+		if err := o.timeErr(); err != nil {
+			return err
+		}
+		return sink.flush()
+	}
+}
+
+// writeRun stores a sorted chunk in a temporary file and returns its
+// name. Each line is prefixed with its length so that runs can hold
+// any bytes, whatever the record delimiter.
+func writeRun(lines []string, tmpDir string) (string, error) {
+	f, err := ioutil.TempFile(tmpDir, "sordid-run-")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close() //nolint:errcheck
+
+	w := bufio.NewWriter(f)
+	var n [binary.MaxVarintLen64]byte
+	for _, line := range lines {
+		w.Write(n[:binary.PutUvarint(n[:], uint64(len(line)))]) //nolint:errcheck
+		w.WriteString(line)                                     //nolint:errcheck
+	}
+	if err := w.Flush(); err != nil {
+		return f.Name(), err
+	}
+	return f.Name(), f.Close()
+}
+
+// runReader is the head of a single sorted run during a merge.
+type runReader struct {
+	read  func() (string, error) // returns io.EOF at the end of the run
+	line  string
+	index int // position of the run, used to keep the merge stable
+}
+
+// next reads the following line of the run, returning io.EOF at its end.
+func (r *runReader) next() (err error) {
+	r.line, err = r.read()
+	return err
+}
+
+// newRunFileReader reads a run written by writeRun.
+func newRunFileReader(f io.Reader) *runReader {
+	br := bufio.NewReader(f)
+	return &runReader{read: func() (string, error) {
+		n, err := binary.ReadUvarint(br)
+		if err != nil {
+			return "", err
+		}
+		buf := make([]byte, n)
+		if _, err := io.ReadFull(br, buf); err != nil {
+			return "", err
+		}
+		return string(buf), nil
+	}}
+}
+
+// newRunScanner reads a run from a sorted input.
+func newRunScanner(s *bufio.Scanner) *runReader {
+	return &runReader{read: func() (string, error) {
+		if s.Scan() {
+			return s.Text(), nil
+		}
+		if err := s.Err(); err != nil {
+			return "", err
+		}
+		return "", io.EOF
+	}}
+}
+
+// runHeap orders run heads by their current line. Ties go to the
+// earlier run, which holds the earlier input lines.
+type runHeap struct {
+	runs []*runReader
+	cmp  *comparer
+}
+
+func (h *runHeap) Len() int { return len(h.runs) }
+func (h *runHeap) Less(i, j int) bool {
+	if c := h.cmp.compare(h.runs[i].line, h.runs[j].line); c != 0 {
+		return c < 0
+	}
+	return h.runs[i].index < h.runs[j].index
+}
+func (h *runHeap) Swap(i, j int)      { h.runs[i], h.runs[j] = h.runs[j], h.runs[i] }
+func (h *runHeap) Push(x interface{}) { h.runs = append(h.runs, x.(*runReader)) }
+func (h *runHeap) Pop() interface{} {
+	old := h.runs
+	n := len(old)
+	x := old[n-1]
+	h.runs = old[:n-1]
+	return x
+}
+
+// mergeRuns k-way merges the sorted runs into w.
+func mergeRuns(runs []*runReader, w lineSink, cmp *comparer) error {
+	h := &runHeap{runs: make([]*runReader, 0, len(runs)), cmp: cmp}
+	for i, r := range runs {
+		r.index = i
+		if err := r.next(); err == nil {
+			h.runs = append(h.runs, r)
+		} else if err != io.EOF {
+			return err
+		}
+	}
+	heap.Init(h)
+
+	for h.Len() > 0 {
+		r := h.runs[0]
+		w.write(r.line)
+		if err := r.next(); err == nil {
+			heap.Fix(h, 0)
+			continue
+		} else if err != io.EOF {
+			return err
+		}
+		heap.Pop(h)
+	}
+	return nil
+}
+
+// ParseSize converts a size such as "512M" or "2GiB" into bytes.
+// Suffixes are powers of 1024; a bare number is taken as bytes.
+func ParseSize(s string) (int64, error) {
+	v := strings.ToUpper(strings.TrimSpace(s))
+	v = strings.TrimSuffix(strings.TrimSuffix(v, "IB"), "B")
+
+	mult := int64(1)
+	if v != "" {
+		switch v[len(v)-1] {
+		case 'K':
+			mult = 1 << 10
+		case 'M':
+			mult = 1 << 20
+		case 'G':
+			mult = 1 << 30
+		case 'T':
+			mult = 1 << 40
+		}
+		if mult > 1 {
+			v = v[:len(v)-1]
+		}
+	}
+
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || n < 0 {
+		return 0, errors.New("invalid size: " + s)
+	}
+	return n * mult, nil
+}
@@ -0,0 +1,152 @@
+package sordid
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int64
+	}{
+		{"0", 0},
+		{"100", 100},
+		{"1K", 1 << 10},
+		{"512M", 512 << 20},
+		{"2GiB", 2 << 30},
+		{"3kb", 3 << 10},
+	}
+	for _, test := range tests {
+		got, err := ParseSize(test.in)
+		if err != nil || got != test.want {
+			t.Error("For", test.in, "expected", test.want, "got", got, err)
+		}
+	}
+
+	for _, bad := range []string{"", "M", "-1", "12X"} {
+		if _, err := ParseSize(bad); err == nil {
+			t.Error("expected error for", bad)
+		}
+	}
+}
+
+func TestSortFileSpill(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sordid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	in := "pear\napple\nfig\nbanana\napple\ncherry\ndate\n"
+	want := "apple\napple\nbanana\ncherry\ndate\nfig\npear\n"
+
+	// Limits small enough to force one line per run, a few lines
+	// per run, and a single in-memory chunk.
+	for _, limit := range []int64{1, 80, 0} {
+		path := filepath.Join(dir, "input")
+		if err := ioutil.WriteFile(path, []byte(in), 0644); err != nil {
+			t.Fatal(err)
+		}
+		o := &options{cmp: newComparer(nil, "", keyOpts{}), memLimit: limit, tmpDir: dir}
+		if err := sortFile(path, o); err != nil {
+			t.Fatal(err)
+		}
+		got, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != want {
+			t.Errorf("limit %d: expected %q, got %q", limit, want, got)
+		}
+
+		left, _ := filepath.Glob(filepath.Join(dir, "sordid-run-*"))
+		if len(left) != 0 {
+			t.Errorf("limit %d: runs left behind: %s", limit, strings.Join(left, ", "))
+		}
+	}
+}
+
+func TestSortFileRecords(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sordid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	long := strings.Repeat("x", 1<<20)
+	tests := []struct {
+		nul      bool
+		in, want string
+	}{
+		{false, "z\n" + long + "\na\n", "a\n" + long + "\nz\n"},
+		{true, "b\nline\x00a\x01\x00c\r\n\x00", "a\x01\x00b\nline\x00c\r\n\x00"},
+		{true, "./b\x00./a", "./a\x00./b"},
+	}
+	for _, test := range tests {
+		for _, limit := range []int64{1, 0} {
+			path := filepath.Join(dir, "input")
+			if err := ioutil.WriteFile(path, []byte(test.in), 0644); err != nil {
+				t.Fatal(err)
+			}
+			o := &options{cmp: newComparer(nil, "", keyOpts{}), memLimit: limit, tmpDir: dir, nul: test.nul}
+			if err := sortFile(path, o); err != nil {
+				t.Fatal(err)
+			}
+			got, err := ioutil.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != test.want {
+				t.Errorf("limit %d: expected %.40q, got %.40q", limit, test.want, got)
+			}
+		}
+	}
+}
+
+func stringInputs(data ...string) []*input {
+	var inputs []*input
+	for _, d := range data {
+		in := &input{ReadCloser: ioutil.NopCloser(strings.NewReader(d)), format: &textFormat{}}
+		in.scanner = newLineScanner(in, in.format)
+		inputs = append(inputs, in)
+	}
+	return inputs
+}
+
+func TestSortInputsCombined(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sordid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var got strings.Builder
+	write := func(fill func(w io.Writer) error) error { return fill(&got) }
+	format := &textFormat{finalNewline: true}
+
+	for _, limit := range []int64{1, 0} {
+		got.Reset()
+		o := &options{cmp: newComparer(nil, "", keyOpts{}), memLimit: limit, tmpDir: dir}
+		// The first input lacks a final newline, which must not join it to the next.
+		if err := sortInputs(stringInputs("d\nb", "c\na\n"), o, format, write); err != nil {
+			t.Fatal(err)
+		}
+		if got.String() != "a\nb\nc\nd\n" {
+			t.Errorf("limit %d: got %q", limit, got.String())
+		}
+	}
+
+	got.Reset()
+	o := &options{cmp: newComparer(nil, "", keyOpts{mode: modeNumeric})}
+	if err := mergeInputs(stringInputs("1\n5\n10\n", "2\n3\n", "20\n"), o, format, write); err != nil {
+		t.Fatal(err)
+	}
+	if got.String() != "1\n2\n3\n5\n10\n20\n" {
+		t.Errorf("merge: got %q", got.String())
+	}
+}
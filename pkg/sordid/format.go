@@ -0,0 +1,117 @@
+package sordid
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+)
+
+// maxRecord lifts bufio.Scanner's 64 KiB token limit so that records
+// of any length can be sorted.
+const maxRecord = int(^uint(0) >> 1)
+
+// utf8BOM is the byte order mark some editors put at the start of UTF-8 files.
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// textFormat records the byte order mark, line ending and final newline
+// of a file as it is read, so that rewriting it keeps them intact.
+// With nul set, records end in NUL bytes and are passed through as is.
+type textFormat struct {
+	nul          bool
+	bom          bool
+	crlf         bool // lines end in "\r\n", judged by the first line
+	finalNewline bool // the last line was terminated
+
+	started bool
+}
+
+// split is a bufio.SplitFunc that behaves like bufio.ScanLines, or
+// scanNUL for NUL-terminated records, while noting the format of
+// what it reads.
+func (f *textFormat) split(data []byte, atEOF bool) (int, []byte, error) {
+	if f.nul {
+		advance, token, err := scanNUL(data, atEOF)
+		if advance > 0 {
+			f.finalNewline = advance > len(token)
+		}
+		return advance, token, err
+	}
+
+	advance, token, err := bufio.ScanLines(data, atEOF)
+	if err != nil || advance == 0 {
+		return advance, token, err
+	}
+
+	if !f.started {
+		f.started = true
+		f.crlf = advance == len(token)+2
+		if bytes.HasPrefix(token, utf8BOM) {
+			f.bom = true
+			token = token[len(utf8BOM):]
+		}
+	}
+	f.finalNewline = advance > len(token)
+	return advance, token, nil
+}
+
+// scanNUL is a bufio.SplitFunc returning NUL-terminated records.
+func scanNUL(data []byte, atEOF bool) (int, []byte, error) {
+	if atEOF && len(data) == 0 {
+		return 0, nil, nil
+	}
+	if i := bytes.IndexByte(data, 0); i >= 0 {
+		return i + 1, data[:i], nil
+	}
+	if atEOF {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
+
+// newLineScanner returns a scanner over r whose lines feed f.
+func newLineScanner(r io.Reader, f *textFormat) *bufio.Scanner {
+	s := bufio.NewScanner(r)
+	s.Buffer(nil, maxRecord)
+	s.Split(f.split)
+	return s
+}
+
+// lineWriter writes lines back out in a recorded textFormat.
+type lineWriter struct {
+	w      *bufio.Writer
+	format *textFormat
+	eol    string
+	count  int
+}
+
+func newLineWriter(w io.Writer, f *textFormat) *lineWriter {
+	lw := &lineWriter{w: bufio.NewWriter(w), format: f, eol: "\n"}
+	switch {
+	case f.nul:
+		lw.eol = "\x00"
+	case f.crlf:
+		lw.eol = "\r\n"
+	}
+	return lw
+}
+
+// write adds a line. The terminator is written ahead of the next line
+// so the last one can be left open if the original was.
+func (lw *lineWriter) write(line string) {
+	if lw.count == 0 && lw.format.bom {
+		lw.w.Write(utf8BOM) //nolint:errcheck
+	}
+	if lw.count > 0 {
+		lw.w.WriteString(lw.eol) //nolint:errcheck
+	}
+	lw.w.WriteString(line) //nolint:errcheck
+	lw.count++
+}
+
+// flush terminates the last line if needed and flushes the output.
+func (lw *lineWriter) flush() error {
+	if lw.count > 0 && lw.format.finalNewline {
+		lw.w.WriteString(lw.eol) //nolint:errcheck
+	}
+	return lw.w.Flush()
+}
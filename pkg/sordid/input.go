@@ -0,0 +1,83 @@
+package sordid
+
+import (
+	"bufio"
+	"io"
+	"io/ioutil"
+	"os"
+)
+
+// Stdio is the file name that stands for standard input and output.
+const Stdio = "-"
+
+// input is an open source of lines and the format noted while reading it.
+type input struct {
+	io.ReadCloser
+	format  *textFormat
+	scanner *bufio.Scanner
+}
+
+// openInput opens the named file, or standard input for "-".
+func openInput(name string, nul bool) (*input, error) {
+	var r io.ReadCloser = ioutil.NopCloser(os.Stdin)
+	if name != Stdio {
+		f, err := os.Open(name)
+		if err != nil {
+			return nil, err
+		}
+		r = f
+	}
+	return newInput(r, nul), nil
+}
+
+// newInput reads lines from r.
+func newInput(r io.ReadCloser, nul bool) *input {
+	in := &input{ReadCloser: r, format: &textFormat{nul: nul}}
+	in.scanner = newLineScanner(r, in.format)
+	return in
+}
+
+// openInputs opens all the named inputs, closing them again on failure.
+func openInputs(names []string, nul bool) ([]*input, error) {
+	inputs := make([]*input, 0, len(names))
+	for _, name := range names {
+		in, err := openInput(name, nul)
+		if err != nil {
+			closeInputs(inputs)
+			return nil, err
+		}
+		inputs = append(inputs, in)
+	}
+	return inputs, nil
+}
+
+func closeInputs(inputs []*input) {
+	for _, in := range inputs {
+		in.Close() //nolint:errcheck
+	}
+}
+
+// writeOutput returns the writeFunc for a combined output: standard
+// output for "-", otherwise the named file, replaced atomically if it
+// exists already.
+func writeOutput(name string) writeFunc {
+	return func(fill func(w io.Writer) error) error {
+		if name == Stdio {
+			return fill(os.Stdout)
+		}
+		if _, err := os.Lstat(name); err == nil {
+			return atomicWrite(name, fill)
+		}
+
+		f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0666)
+		if err != nil {
+			return err
+		}
+		if err := fill(f); err != nil {
+			f.Close()       //nolint:errcheck
+			os.Remove(name) //nolint:errcheck
+			return err
+		}
+		return f.Close()
+	}
+}
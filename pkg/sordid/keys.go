@@ -0,0 +1,253 @@
+package sordid
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/text/collate"
+)
+
+// keySpec describes one sort key in the style of POSIX sort -k:
+// it runs from character startChar of field startField through
+// character endChar of field endField. Fields and characters are
+// counted from one; an endField of zero means the end of the line
+// and an endChar of zero means the end of the field.
+type keySpec struct {
+	startField, startChar int
+	endField, endChar     int
+
+	keyOpts
+	hasOpts bool // the key carries its own options and ignores the globals
+}
+
+// keyOpts are the ordering options of a key, which can also be given
+// globally for keys that have none of their own.
+type keyOpts struct {
+	skipStartBlanks bool // 'b' on the start position
+	skipEndBlanks   bool // 'b' on the end position
+	reverse         bool
+	mode            compareMode
+
+	foldCase    bool // 'f': ignore case
+	foldAccents bool // 'A': ignore diacritics
+	dictionary  bool // 'd': only blanks, letters and digits count
+	printable   bool // 'i': only printable characters count
+
+	// locale is the language whose collation rules modeCollate
+	// follows. It is only ever set globally.
+	locale string
+	coll   *collate.Collator
+
+	// timeLayout, timeZone and strictTime configure modeTime, and
+	// are likewise only set globally.
+	timeLayout string
+	timeZone   *time.Location
+	strictTime bool // unparseable times are an error rather than sorting last
+	times      *timeKey
+}
+
+// parseKey parses a key definition such as "2", "2,3", "1.3,1.5" or
+// "3,3nr". Option letters may follow either position.
+func parseKey(s string) (keySpec, error) {
+	k := keySpec{startChar: 1}
+	bad := errors.New("invalid key: " + s)
+
+	start, end := s, ""
+	if i := strings.IndexByte(s, ','); i >= 0 {
+		start, end = s[:i], s[i+1:]
+		if end == "" {
+			return k, bad
+		}
+	}
+
+	field, char, opts, err := parsePos(start)
+	if err != nil || field < 1 {
+		return k, bad
+	}
+	if char == 0 {
+		char = 1
+	}
+	k.startField, k.startChar = field, char
+	if err := k.applyOpts(opts, false); err != nil {
+		return k, err
+	}
+
+	if end != "" {
+		field, char, opts, err := parsePos(end)
+		if err != nil || field < 1 {
+			return k, bad
+		}
+		k.endField, k.endChar = field, char
+		if err := k.applyOpts(opts, true); err != nil {
+			return k, err
+		}
+	}
+	return k, nil
+}
+
+// parsePos splits a key position "F[.C][OPTS]" into its parts.
+// An omitted character offset is returned as zero.
+func parsePos(s string) (field, char int, opts string, err error) {
+	i := skipDigits(s, 0)
+	if field, err = strconv.Atoi(s[:i]); err != nil {
+		return
+	}
+	if i < len(s) && s[i] == '.' {
+		j := skipDigits(s, i+1)
+		if char, err = strconv.Atoi(s[i+1 : j]); err != nil {
+			return
+		}
+		i = j
+	}
+	opts = s[i:]
+	return
+}
+
+func skipDigits(s string, i int) int {
+	for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+		i++
+	}
+	return i
+}
+
+// applyOpts records the option letters attached to a key position.
+func (k *keySpec) applyOpts(opts string, end bool) error {
+	for _, o := range opts {
+		switch o {
+		case 'b':
+			if end {
+				k.skipEndBlanks = true
+			} else {
+				k.skipStartBlanks = true
+			}
+		case 'r':
+			k.reverse = true
+		case 'f':
+			k.foldCase = true
+		case 'A':
+			k.foldAccents = true
+		case 'd':
+			k.dictionary = true
+		case 'i':
+			k.printable = true
+		default:
+			m, ok := modeFlags[o]
+			if !ok {
+				return errors.New("unknown key option: " + string(o))
+			}
+			k.mode = m
+		}
+		k.hasOpts = true
+	}
+	return nil
+}
+
+// extract returns the part of line covered by the key, using sep
+// as the field separator or runs of blanks when sep is empty.
+func (k *keySpec) extract(line, sep string) string {
+	s := fieldStart(line, sep, k.startField)
+	if k.skipStartBlanks {
+		s = skipBlanks(line, s)
+	}
+	s = advanceChars(line, s, k.startChar-1)
+
+	e := len(line)
+	if k.endField > 0 {
+		e = fieldStart(line, sep, k.endField)
+		if k.endChar == 0 {
+			e = fieldEnd(line, sep, e)
+		} else {
+			if k.skipEndBlanks {
+				e = skipBlanks(line, e)
+			}
+			e = advanceChars(line, e, k.endChar)
+		}
+	}
+
+	if e <= s {
+		return ""
+	}
+	return line[s:e]
+}
+
+// keyList collects sort keys given one at a time.
+type keyList []keySpec
+
+func (l *keyList) String() string { return strconv.Itoa(len(*l)) + " keys" }
+
+func (l *keyList) Set(s string) error {
+	k, err := parseKey(s)
+	if err != nil {
+		return err
+	}
+	*l = append(*l, k)
+	return nil
+}
+
+// fieldStart returns the byte offset where field n (from one) begins.
+// Without a separator a field is any leading blanks plus the
+// non-blank run after them, as with POSIX sort.
+func fieldStart(line, sep string, n int) int {
+	i := 0
+	for f := 1; f < n && i < len(line); f++ {
+		if sep != "" {
+			j := strings.Index(line[i:], sep)
+			if j < 0 {
+				return len(line)
+			}
+			i += j + len(sep)
+			continue
+		}
+		i = fieldEnd(line, sep, i)
+	}
+	return i
+}
+
+// fieldEnd returns the byte offset just past the field starting at i.
+func fieldEnd(line, sep string, i int) int {
+	if sep != "" {
+		j := strings.Index(line[i:], sep)
+		if j < 0 {
+			return len(line)
+		}
+		return i + j
+	}
+	i = skipBlanks(line, i)
+	for i < len(line) && !isBlank(line[i]) {
+		i++
+	}
+	return i
+}
+
+func skipBlanks(line string, i int) int {
+	for i < len(line) && isBlank(line[i]) {
+		i++
+	}
+	return i
+}
+
+func isBlank(c byte) bool { return c == ' ' || c == '\t' }
+
+// advanceChars moves n characters forward from byte offset i.
+func advanceChars(line string, i, n int) int {
+	for ; n > 0 && i < len(line); n-- {
+		_, size := utf8.DecodeRuneInString(line[i:])
+		i += size
+	}
+	return i
+}
+
+// parseSeparator expands the escapes people reach for when passing
+// a tab or NUL separator on the command line.
+func parseSeparator(s string) string {
+	switch s {
+	case `\t`:
+		return "\t"
+	case `\0`:
+		return "\x00"
+	}
+	return s
+}
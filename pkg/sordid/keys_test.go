@@ -0,0 +1,89 @@
+package sordid
+
+import (
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestKeyExtract(t *testing.T) {
+	tests := []struct {
+		key  string
+		sep  string
+		line string
+		want string
+	}{
+		{"2", "", "a  bb ccc", "  bb ccc"},
+		{"2,2", "", "a  bb ccc", "  bb"},
+		{"2b,2", "", "a  bb ccc", "bb"},
+		{"2.2b,2.3b", "", "a  bcd e", "cd"},
+		{"3,3", "\t", "a\tb\tc c\td", "c c"},
+		{"2,3", ",", "a,b,c,d", "b,c"},
+		{"5", ",", "a,b", ""},
+		{"1.2,1.3", "", "héllo", "él"},
+	}
+	for _, test := range tests {
+		k, err := parseKey(test.key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := k.extract(test.line, test.sep); got != test.want {
+			t.Errorf("key %s on %q: expected %q, got %q", test.key, test.line, test.want, got)
+		}
+	}
+
+	for _, bad := range []string{"", "0", "a", "1,", "1x", "1.2.3"} {
+		if _, err := parseKey(bad); err == nil {
+			t.Error("expected error for key", bad)
+		}
+	}
+}
+
+func TestComparerKeys(t *testing.T) {
+	tests := []struct {
+		keys   []string
+		sep    string
+		global keyOpts
+		in     []string
+		want   []string
+	}{
+		{nil, "", keyOpts{}, []string{"b", "a", "c"}, []string{"a", "b", "c"}},
+		{nil, "", keyOpts{reverse: true}, []string{"b", "a", "c"}, []string{"c", "b", "a"}},
+		{
+			[]string{"2,2n"}, "\t", keyOpts{},
+			[]string{"x\t10", "y\t9", "z\t-1"},
+			[]string{"z\t-1", "y\t9", "x\t10"},
+		},
+		{
+			// Primary key descending, ties kept in input order.
+			[]string{"1,1r"}, ",", keyOpts{},
+			[]string{"a,3", "b,1", "a,2", "b,2"},
+			[]string{"b,1", "b,2", "a,3", "a,2"},
+		},
+		{
+			[]string{"1,1", "2,2nr"}, ",", keyOpts{},
+			[]string{"a,3", "b,1", "a,20", "b,2"},
+			[]string{"a,20", "a,3", "b,2", "b,1"},
+		},
+		{
+			// Keys without options inherit the global ones.
+			[]string{"2"}, "", keyOpts{mode: modeNumeric},
+			[]string{"x 10", "y 9"},
+			[]string{"y 9", "x 10"},
+		},
+	}
+	for _, test := range tests {
+		var keys keyList
+		for _, k := range test.keys {
+			if err := keys.Set(k); err != nil {
+				t.Fatal(err)
+			}
+		}
+		c := newComparer(keys, test.sep, test.global)
+		got := append([]string(nil), test.in...)
+		sort.SliceStable(got, func(i, j int) bool { return c.less(got[i], got[j]) })
+		if strings.Join(got, "|") != strings.Join(test.want, "|") {
+			t.Errorf("keys %v: expected %q, got %q", test.keys, test.want, got)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+//go:build linux || darwin
+// +build linux darwin
+
+package sordid
+
+import (
+	"bytes"
+	"os"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// copyMetadata gives f the owner and extended attributes of the file
+// at path. Both are best effort: an unprivileged user cannot hand a
+// file to someone else, and not every filesystem has xattrs.
+func copyMetadata(f *os.File, path string, info os.FileInfo) error {
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		if err := f.Chown(int(st.Uid), int(st.Gid)); err != nil && !os.IsPermission(err) {
+			return err
+		}
+	}
+
+	size, err := unix.Listxattr(path, nil)
+	if err != nil || size == 0 {
+		return nil
+	}
+	names := make([]byte, size)
+	if size, err = unix.Listxattr(path, names); err != nil {
+		return nil
+	}
+	for _, name := range bytes.Split(names[:size], []byte{0}) {
+		if len(name) == 0 {
+			continue
+		}
+		attr := string(name)
+		n, err := unix.Getxattr(path, attr, nil)
+		if err != nil {
+			continue
+		}
+		value := make([]byte, n)
+		if n, err = unix.Getxattr(path, attr, value); err != nil {
+			continue
+		}
+		unix.Fsetxattr(int(f.Fd()), attr, value[:n], 0) //nolint:errcheck
+	}
+	return nil
+}
@@ -0,0 +1,155 @@
+package sordid
+
+import (
+	"bytes"
+	"net"
+	"strings"
+)
+
+// parseIP reads an IPv4 or IPv6 address, with or without the brackets
+// of URLs. IPv4 addresses come back in their 4-byte form.
+func parseIP(s string) net.IP {
+	s = strings.TrimSpace(s)
+	s = strings.TrimSuffix(strings.TrimPrefix(s, "["), "]")
+	ip := net.ParseIP(s)
+	if v4 := ip.To4(); v4 != nil {
+		return v4
+	}
+	return ip
+}
+
+// compareIPs orders addresses numerically, IPv4 before IPv6. Keys that
+// are not addresses sort after all that are, by their text.
+func compareIPs(a, b string) int {
+	ia, ib := parseIP(a), parseIP(b)
+	switch {
+	case ia != nil && ib != nil:
+		if c := compareInts(len(ia), len(ib)); c != 0 {
+			return c
+		}
+		return bytes.Compare(ia, ib)
+	case ia != nil || ib != nil:
+		return compareBools(ia == nil, ib == nil)
+	}
+	return strings.Compare(a, b)
+}
+
+// parseNet reads a CIDR block such as "10.0.0.0/8". A bare address is
+// taken as a block holding just itself. The address as written is
+// returned too, as it may have host bits set.
+func parseNet(s string) (net.IP, *net.IPNet) {
+	s = strings.TrimSpace(s)
+	if !strings.Contains(s, "/") {
+		ip := parseIP(s)
+		if ip == nil {
+			return nil, nil
+		}
+		bits := 8 * len(ip)
+		return ip, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}
+	}
+	ip, n, err := net.ParseCIDR(s)
+	if err != nil {
+		return nil, nil
+	}
+	if v4 := ip.To4(); v4 != nil {
+		ip = v4
+		n.IP = n.IP.To4()
+	}
+	return ip, n
+}
+
+// compareCIDRs orders blocks by network address, IPv4 before IPv6,
+// then by prefix length, so that a block comes right before the
+// smaller blocks it holds. Keys that are not blocks sort last.
+func compareCIDRs(a, b string) int {
+	ia, na := parseNet(a)
+	ib, nb := parseNet(b)
+	switch {
+	case na != nil && nb != nil:
+		if c := compareInts(len(na.IP), len(nb.IP)); c != 0 {
+			return c
+		}
+		if c := bytes.Compare(na.IP, nb.IP); c != 0 {
+			return c
+		}
+		oa, _ := na.Mask.Size()
+		ob, _ := nb.Mask.Size()
+		if c := compareInts(oa, ob); c != 0 {
+			return c
+		}
+		return bytes.Compare(ia, ib)
+	case na != nil || nb != nil:
+		return compareBools(na == nil, nb == nil)
+	}
+	return strings.Compare(a, b)
+}
+
+// netContains reports whether block a holds all of block b.
+func netContains(a, b *net.IPNet) bool {
+	oa, _ := a.Mask.Size()
+	ob, _ := b.Mask.Size()
+	return len(a.IP) == len(b.IP) && oa <= ob && a.Contains(b.IP)
+}
+
+// compareHosts orders host names by their labels from the top level
+// down, so that "example.com" sorts right before "a.example.com" and
+// "www.example.com". Labels compare without regard to case, with
+// numbers in them by value.
+func compareHosts(a, b string) int {
+	la, lb := hostLabels(a), hostLabels(b)
+	for i := 0; i < len(la) && i < len(lb); i++ {
+		if c := compareNatural(la[i], lb[i]); c != 0 {
+			return c
+		}
+	}
+	return compareInts(len(la), len(lb))
+}
+
+// hostLabels returns the labels of a host name, top level first.
+func hostLabels(s string) []string {
+	s = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(s)), ".")
+	if s == "" {
+		return nil
+	}
+	labels := strings.Split(s, ".")
+	for i, j := 0, len(labels)-1; i < j; i, j = i+1, j-1 {
+		labels[i], labels[j] = labels[j], labels[i]
+	}
+	return labels
+}
+
+// collapser is a lineSink that drops lines whose CIDR block lies
+// within the block of an earlier line. It relies on the order of
+// compareCIDRs, where a block comes before those it holds.
+type collapser struct {
+	key  *keySpec
+	sep  string
+	out  lineSink
+	last *net.IPNet
+}
+
+func (c *collapser) write(line string) { c.add([]string{line}) }
+
+// add takes the next item in sorted order, keyed by its first line.
+func (c *collapser) add(item []string) {
+	_, n := parseNet(c.key.extract(item[0], c.sep))
+	if n != nil {
+		if c.last != nil && netContains(c.last, n) {
+			return
+		}
+		c.last = n
+	}
+	addItem(c.out, item)
+}
+
+func (c *collapser) flush() error { return c.out.flush() }
+
+// cidrKey returns the first key ordering CIDR blocks, or nil.
+func (c *comparer) cidrKey() *keySpec {
+	for i := range c.keys {
+		if c.keys[i].mode == modeCIDR {
+			return &c.keys[i]
+		}
+	}
+	return nil
+}
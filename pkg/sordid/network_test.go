@@ -0,0 +1,57 @@
+package sordid
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNetworkModes(t *testing.T) {
+	tests := []struct {
+		opts []Option
+		in   string
+		want string
+	}{
+		{
+			[]Option{Mode("ip")},
+			"10.0.0.10\n::1\nhost\n10.0.0.9\n[2001:db8::1]\n9.255.255.255\n",
+			"9.255.255.255\n10.0.0.9\n10.0.0.10\n::1\n[2001:db8::1]\nhost\n",
+		},
+		{
+			[]Option{Mode("cidr")},
+			"10.1.0.0/16\n2001:db8::/32\n10.0.0.0/8\n10.0.0.0/24\n10.0.0.5\n10.0.0.0/16\n",
+			"10.0.0.0/8\n10.0.0.0/16\n10.0.0.0/24\n10.0.0.5\n10.1.0.0/16\n2001:db8::/32\n",
+		},
+		{
+			[]Option{Mode("cidr"), CollapseCIDRs()},
+			"10.1.0.0/16\n192.168.1.0/24\n10.0.0.0/8\n10.0.0.5\n192.168.0.0/24\n192.168.1.0/24\n",
+			"10.0.0.0/8\n192.168.0.0/24\n192.168.1.0/24\n",
+		},
+		{
+			[]Option{Key("2,2C"), CollapseCIDRs()},
+			"b 10.0.0.0/24\na 10.0.0.0/16\nc 172.16.0.1\n",
+			"a 10.0.0.0/16\nc 172.16.0.1\n",
+		},
+		{
+			[]Option{Mode("host")},
+			"www.example.com\nexample.org\nExample.com.\nweb10.example.com\nweb2.example.com\na.example.com\n",
+			"Example.com.\na.example.com\nweb2.example.com\nweb10.example.com\nwww.example.com\nexample.org\n",
+		},
+	}
+	for _, test := range tests {
+		s, err := New(test.opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got strings.Builder
+		if err := s.Sort(&got, strings.NewReader(test.in)); err != nil {
+			t.Fatal(err)
+		}
+		if got.String() != test.want {
+			t.Errorf("for %q expected %q, got %q", test.in, test.want, got.String())
+		}
+	}
+
+	if _, err := New(Mode("ip"), CollapseCIDRs()); err == nil {
+		t.Error("expected collapsing without a cidr key to fail")
+	}
+}
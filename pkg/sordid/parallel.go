@@ -0,0 +1,94 @@
+package sordid
+
+import (
+	"sort"
+	"sync"
+)
+
+// minPartLines is the fewest lines worth handing to a goroutine of
+// their own; below it the cost of merging outweighs the gain.
+const minPartLines = 1 << 13
+
+// sortStable sorts lines in place by cmp, keeping equal lines in order.
+func sortStable(lines []string, cmp *comparer) {
+	sort.SliceStable(lines, func(i, j int) bool { return cmp.less(lines[i], lines[j]) })
+}
+
+// sortParallel stably sorts lines in place using up to workers
+// goroutines. The lines are cut into contiguous parts that are sorted
+// concurrently, then neighbouring parts are merged pairwise with ties
+// going to the left one, so the result is the same as sortStable's.
+func sortParallel(lines []string, cmp *comparer, workers int) {
+	if n := len(lines) / minPartLines; workers > n {
+		workers = n
+	}
+	if workers < 2 {
+		sortStable(lines, cmp)
+		return
+	}
+
+	// Collators keep state between comparisons, so every goroutine
+	// needs a comparer of its own.
+	cmps := make([]*comparer, workers)
+	cmps[0] = cmp
+	for i := 1; i < workers; i++ {
+		cmps[i] = cmp.clone()
+	}
+
+	bounds := make([]int, workers+1)
+	for i := range bounds {
+		bounds[i] = len(lines) * i / workers
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(part []string, cmp *comparer) {
+			defer wg.Done()
+			sortStable(part, cmp)
+		}(lines[bounds[i]:bounds[i+1]], cmps[i])
+	}
+	wg.Wait()
+
+	src, dst := lines, make([]string, len(lines))
+	for len(bounds) > 2 {
+		next := []int{0}
+		for i := 0; i+1 < len(bounds); i += 2 {
+			lo, mid := bounds[i], bounds[i+1]
+			if i+2 == len(bounds) {
+				// An odd part out waits for the next round.
+				copy(dst[lo:mid], src[lo:mid])
+				next = append(next, mid)
+				continue
+			}
+			hi := bounds[i+2]
+			wg.Add(1)
+			go func(out, a, b []string, cmp *comparer) {
+				defer wg.Done()
+				mergeParts(out, a, b, cmp)
+			}(dst[lo:hi], src[lo:mid], src[mid:hi], cmps[i/2])
+			next = append(next, hi)
+		}
+		wg.Wait()
+		src, dst = dst, src
+		bounds = next
+	}
+	if &src[0] != &lines[0] {
+		copy(lines, src)
+	}
+}
+
+// mergeParts merges the sorted slices a and b into out, taking from a
+// when lines compare equal.
+func mergeParts(out, a, b []string, cmp *comparer) {
+	i := 0
+	for len(a) > 0 && len(b) > 0 {
+		if cmp.less(b[0], a[0]) {
+			out[i], b = b[0], b[1:]
+		} else {
+			out[i], a = a[0], a[1:]
+		}
+		i++
+	}
+	i += copy(out[i:], a)
+	copy(out[i:], b)
+}
@@ -0,0 +1,48 @@
+package sordid
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+func TestSortParallelMatchesStable(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	lines := make([]string, 5*minPartLines+17)
+	for i := range lines {
+		// Few distinct keys and a unique tail make ties whose order shows.
+		lines[i] = fmt.Sprintf("%d %d", rng.Intn(100), i)
+	}
+
+	comparers := map[string]*comparer{
+		"lexical": newComparer(nil, "", keyOpts{}),
+		"numeric": newComparer([]keySpec{{startField: 1, startChar: 1, endField: 1}}, "", keyOpts{mode: modeNumeric}),
+		"collate": newComparer([]keySpec{{startField: 1, startChar: 1, endField: 1}}, "", keyOpts{mode: modeCollate, locale: "de"}),
+	}
+	for name, cmp := range comparers {
+		want := append([]string(nil), lines...)
+		sortStable(want, cmp)
+		for _, workers := range []int{1, 2, 3, 5, 8} {
+			got := append([]string(nil), lines...)
+			sortParallel(got, cmp, workers)
+			for i := range want {
+				if got[i] != want[i] {
+					t.Errorf("%s, %d workers: line %d is %q, expected %q", name, workers, i, got[i], want[i])
+					break
+				}
+			}
+		}
+	}
+}
+
+func TestMergeParts(t *testing.T) {
+	cmp := newComparer([]keySpec{{startField: 1, startChar: 1, endField: 1}}, "", keyOpts{})
+	out := make([]string, 5)
+	mergeParts(out, []string{"a 1", "b 1", "c 1"}, []string{"a 2", "b 2"}, cmp)
+	want := []string{"a 1", "a 2", "b 1", "b 2", "c 1"}
+	for i := range want {
+		if out[i] != want[i] {
+			t.Fatalf("expected %q, got %q", want, out)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package sordid
+
+import (
+	"container/heap"
+	"math/rand"
+	"sort"
+)
+
+// pickKind is a way of picking lines rather than sorting them all.
+type pickKind int
+
+const (
+	pickTop     pickKind = iota // the greatest lines, greatest first
+	pickBottom                  // the least lines, least first
+	pickShuffle                 // every line, in random order
+	pickSample                  // random lines, in input order
+)
+
+// pickSpec picks lines from the input instead of sorting all of it.
+// Top, bottom and sample hold only n lines in memory at a time.
+type pickSpec struct {
+	kind pickKind
+	n    int   // lines to keep, for all but shuffle
+	seed int64 // seed of the random choices of shuffle and sample
+}
+
+// picker takes lines one at a time and hands back those picked.
+type picker interface {
+	add(line string)
+	lines() []string
+}
+
+// newPicker returns a picker for the spec, comparing lines with cmp.
+func (p *pickSpec) newPicker(cmp *comparer) picker {
+	switch p.kind {
+	case pickTop, pickBottom:
+		sign := 1
+		if p.kind == pickTop {
+			sign = -1
+		}
+		return &boundedHeap{n: p.n, sign: sign, cmp: cmp}
+	case pickShuffle:
+		return &shuffler{rand: rand.New(rand.NewSource(p.seed))}
+	}
+	return &reservoir{n: p.n, rand: rand.New(rand.NewSource(p.seed))}
+}
+
+// pickLines picks from lines held in memory.
+func (p *pickSpec) pickLines(lines []string, cmp *comparer) []string {
+	pk := p.newPicker(cmp)
+	for _, line := range lines {
+		pk.add(line)
+	}
+	return pk.lines()
+}
+
+// pickInputs picks from the lines of all the inputs, read in turn.
+func pickInputs(inputs []*input, o *options) ([]string, error) {
+	pk := o.pick.newPicker(o.cmp)
+	for _, in := range inputs {
+		for in.scanner.Scan() {
+			pk.add(in.scanner.Text())
+		}
+		if err := in.scanner.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return pk.lines(), o.timeErr()
+}
+
+// pickedLine is a line and its position in the input.
+type pickedLine struct {
+	line  string
+	index int
+}
+
+// boundedHeap keeps the first n lines in sorted order, or in reverse
+// order for a negative sign, without holding more than n at a time.
+// Of equal lines the earlier ones are kept, as a stable sort would.
+// The root of the heap is the line that would be dropped next.
+type boundedHeap struct {
+	n     int
+	sign  int
+	cmp   *comparer
+	kept  []pickedLine
+	count int
+}
+
+func (h *boundedHeap) Len() int { return len(h.kept) }
+func (h *boundedHeap) Less(i, j int) bool {
+	if c := h.sign * h.cmp.compare(h.kept[i].line, h.kept[j].line); c != 0 {
+		return c > 0
+	}
+	return h.kept[i].index > h.kept[j].index
+}
+func (h *boundedHeap) Swap(i, j int)      { h.kept[i], h.kept[j] = h.kept[j], h.kept[i] }
+func (h *boundedHeap) Push(x interface{}) { h.kept = append(h.kept, x.(pickedLine)) }
+func (h *boundedHeap) Pop() interface{} {
+	old := h.kept
+	x := old[len(old)-1]
+	h.kept = old[:len(old)-1]
+	return x
+}
+
+func (h *boundedHeap) add(line string) {
+	l := pickedLine{line: line, index: h.count}
+	h.count++
+	switch {
+	case len(h.kept) < h.n:
+		heap.Push(h, l)
+	case h.n > 0 && h.sign*h.cmp.compare(line, h.kept[0].line) < 0:
+		h.kept[0] = l
+		heap.Fix(h, 0)
+	}
+}
+
+// lines empties the heap, returning the lines kept in order.
+func (h *boundedHeap) lines() []string {
+	lines := make([]string, len(h.kept))
+	for i := len(lines) - 1; i >= 0; i-- {
+		lines[i] = heap.Pop(h).(pickedLine).line
+	}
+	return lines
+}
+
+// shuffler holds every line to return them in random order.
+type shuffler struct {
+	rand *rand.Rand
+	all  []string
+}
+
+func (s *shuffler) add(line string) { s.all = append(s.all, line) }
+
+func (s *shuffler) lines() []string {
+	s.rand.Shuffle(len(s.all), func(i, j int) { s.all[i], s.all[j] = s.all[j], s.all[i] })
+	return s.all
+}
+
+// reservoir keeps a uniform random sample of n lines, however many it
+// is given, returning them in the order they came.
+type reservoir struct {
+	n     int
+	rand  *rand.Rand
+	kept  []pickedLine
+	count int
+}
+
+func (r *reservoir) add(line string) {
+	l := pickedLine{line: line, index: r.count}
+	r.count++
+	if len(r.kept) < r.n {
+		r.kept = append(r.kept, l)
+		return
+	}
+	if i := r.rand.Int63n(int64(r.count)); i < int64(r.n) {
+		r.kept[i] = l
+	}
+}
+
+func (r *reservoir) lines() []string {
+	sort.Slice(r.kept, func(i, j int) bool { return r.kept[i].index < r.kept[j].index })
+	lines := make([]string, len(r.kept))
+	for i, l := range r.kept {
+		lines[i] = l.line
+	}
+	return lines
+}
@@ -0,0 +1,152 @@
+package sordid
+
+import (
+	"math/rand"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestPickTopBottom(t *testing.T) {
+	tests := []struct {
+		opts []Option
+		in   []string
+		want []string
+	}{
+		{[]Option{Top(2)}, []string{"b", "d", "a", "c"}, []string{"d", "c"}},
+		{[]Option{Bottom(2)}, []string{"b", "d", "a", "c"}, []string{"a", "b"}},
+		{[]Option{Bottom(5)}, []string{"b", "a"}, []string{"a", "b"}},
+		{[]Option{Top(0)}, []string{"b", "a"}, []string{}},
+		{[]Option{Top(2), Key("1,1n")}, []string{"5 a", "10 b", "5 c", "10 d"}, []string{"10 b", "10 d"}},
+		{[]Option{Bottom(2), Key("1,1n")}, []string{"5 a", "10 b", "5 c", "5 d"}, []string{"5 a", "5 c"}},
+		{[]Option{Top(2), Key("1,1nr")}, []string{"3", "1", "2"}, []string{"1", "2"}},
+	}
+	for _, test := range tests {
+		s, err := New(test.opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := s.SortLines(test.in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != len(test.want) || (len(got) > 0 && !reflect.DeepEqual(got, test.want)) {
+			t.Errorf("for %q expected %q, got %q", test.in, test.want, got)
+		}
+	}
+}
+
+// TestPickMatchesSort checks that top and bottom agree with a stable
+// sort cut short.
+func TestPickMatchesSort(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	var lines []string
+	for i := 0; i < 2000; i++ {
+		lines = append(lines, strconv.Itoa(r.Intn(300))+" "+strconv.Itoa(i))
+	}
+	sorted := append([]string(nil), lines...)
+	key := func(s string) int {
+		n, _ := strconv.Atoi(strings.Fields(s)[0])
+		return n
+	}
+	sort.SliceStable(sorted, func(i, j int) bool { return key(sorted[i]) < key(sorted[j]) })
+	reversed := append([]string(nil), lines...)
+	sort.SliceStable(reversed, func(i, j int) bool { return key(reversed[i]) > key(reversed[j]) })
+
+	for _, test := range []struct {
+		opt  Option
+		want []string
+	}{
+		{Bottom(100), sorted[:100]},
+		{Top(100), reversed[:100]},
+	} {
+		s, err := New(test.opt, Key("1,1n"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := s.SortLines(lines)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("expected %q, got %q", test.want[:5], got[:5])
+		}
+	}
+}
+
+func TestPickRandom(t *testing.T) {
+	var lines []string
+	for i := 0; i < 100; i++ {
+		lines = append(lines, strconv.Itoa(i))
+	}
+	pick := func(opt Option) []string {
+		s, err := New(opt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := s.SortLines(lines)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return got
+	}
+
+	shuffled := pick(Shuffle(42))
+	if !reflect.DeepEqual(shuffled, pick(Shuffle(42))) {
+		t.Error("expected the same seed to shuffle the same way")
+	}
+	if reflect.DeepEqual(shuffled, lines) || reflect.DeepEqual(shuffled, pick(Shuffle(43))) {
+		t.Error("expected shuffles to differ")
+	}
+	all := append([]string(nil), shuffled...)
+	sort.Sort(byNumber(all))
+	if !reflect.DeepEqual(all, lines) {
+		t.Errorf("expected a shuffle to keep every line, got %q", shuffled)
+	}
+
+	sample := pick(Sample(10, 42))
+	if len(sample) != 10 {
+		t.Fatalf("expected 10 lines, got %q", sample)
+	}
+	if !reflect.DeepEqual(sample, pick(Sample(10, 42))) {
+		t.Error("expected the same seed to pick the same sample")
+	}
+	for i := 1; i < len(sample); i++ {
+		a, _ := strconv.Atoi(sample[i-1])
+		b, _ := strconv.Atoi(sample[i])
+		if a >= b {
+			t.Errorf("expected the sample in input order, got %q", sample)
+			break
+		}
+	}
+	if got := pick(Sample(200, 1)); !reflect.DeepEqual(got, lines) {
+		t.Errorf("expected a sample larger than the input to keep it all, got %q", got)
+	}
+}
+
+func TestPickOptionErrors(t *testing.T) {
+	for _, opts := range [][]Option{
+		{Top(1), Shuffle(1)},
+		{Bottom(-1)},
+		{Sample(1, 1), Unique()},
+		{Top(1), Format("csv")},
+		{Top(1), Regions("", "")},
+	} {
+		if _, err := New(opts...); err == nil {
+			t.Errorf("expected an error from %d options", len(opts))
+		}
+	}
+}
+
+// byNumber orders decimal lines by value.
+type byNumber []string
+
+func (b byNumber) Len() int      { return len(b) }
+func (b byNumber) Swap(i, j int) { b[i], b[j] = b[j], b[i] }
+func (b byNumber) Less(i, j int) bool {
+	x, _ := strconv.Atoi(b[i])
+	y, _ := strconv.Atoi(b[j])
+	return x < y
+}
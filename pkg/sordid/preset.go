@@ -0,0 +1,372 @@
+package sordid
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// presetNames are the file formats a preset can sort.
+var presetNames = []string{"goimports", "gitignore", "codeowners", "requirements", "gomod"}
+
+// presetSpec sorts a file in a format whose lines have groups and
+// comments that plain line sorting would break apart.
+type presetSpec struct {
+	name  string
+	local []string // import path prefixes of the local goimports group
+}
+
+// fixedClass marks an entry that never moves.
+const fixedClass = -1
+
+// presetEntry is a line that sorts as a unit with the comments above
+// it and any lines it continues onto.
+type presetEntry struct {
+	lines []string
+	key   string
+	class int // entries move only within a run of the same class
+}
+
+// grammar tells a preset how to take a list of lines apart.
+type grammar struct {
+	comment   func(line string) bool
+	headers   bool                            // comments opening a group stay as its header
+	continued func(line string) bool          // the line carries on onto the next; nil for never
+	entry     func(line string) (string, int) // the sort key and class of a line
+	compare   func(a, b string) int           // orders keys; nil for byte order
+	check     func(run []presetEntry) error   // vets a run before it is sorted; nil for none
+}
+
+// parse splits lines, which hold no blank lines, into the comments
+// heading them, their entries, and any comments left after the last.
+func (g *grammar) parse(lines []string) (header []string, entries []presetEntry, footer []string) {
+	if g.headers {
+		for len(lines) > 0 && g.comment(lines[0]) {
+			header, lines = append(header, lines[0]), lines[1:]
+		}
+	}
+	var pending []string
+	for i := 0; i < len(lines); i++ {
+		if g.comment(lines[i]) {
+			pending = append(pending, lines[i])
+			continue
+		}
+		e := presetEntry{lines: append(pending, lines[i])}
+		pending = nil
+		e.key, e.class = g.entry(lines[i])
+		for g.continued != nil && g.continued(lines[i]) && i+1 < len(lines) {
+			i++
+			e.lines = append(e.lines, lines[i])
+		}
+		entries = append(entries, e)
+	}
+	return header, entries, pending
+}
+
+// sortEntries sorts each run of entries of the same class by key,
+// keeping equal keys in their order.
+func (g *grammar) sortEntries(entries []presetEntry) error {
+	compare := g.compare
+	if compare == nil {
+		compare = strings.Compare
+	}
+	for start := 0; start < len(entries); {
+		end := start + 1
+		for end < len(entries) && entries[end].class == entries[start].class {
+			end++
+		}
+		run := entries[start:end]
+		if run[0].class != fixedClass {
+			if g.check != nil {
+				if err := g.check(run); err != nil {
+					return err
+				}
+			}
+			sort.SliceStable(run, func(i, j int) bool { return compare(run[i].key, run[j].key) < 0 })
+		}
+		start = end
+	}
+	return nil
+}
+
+// sortGroups sorts each blank-line separated group of lines on its own.
+func (g *grammar) sortGroups(lines []string) ([]string, error) {
+	out := make([]string, 0, len(lines))
+	group := 0
+	for i := 0; i <= len(lines); i++ {
+		if i < len(lines) && strings.TrimSpace(lines[i]) != "" {
+			continue
+		}
+		header, entries, footer := g.parse(lines[group:i])
+		if err := g.sortEntries(entries); err != nil {
+			return nil, err
+		}
+		out = append(out, header...)
+		out = appendEntries(out, entries)
+		out = append(out, footer...)
+		if i < len(lines) {
+			out = append(out, lines[i])
+		}
+		group = i + 1
+	}
+	return out, nil
+}
+
+func appendEntries(out []string, entries []presetEntry) []string {
+	for _, e := range entries {
+		out = append(out, e.lines...)
+	}
+	return out
+}
+
+// parsePreset checks a preset name.
+func parsePreset(name string) error {
+	for _, n := range presetNames {
+		if n == name {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown preset %s, want one of %s", name, strings.Join(presetNames, ", "))
+}
+
+// sortLines sorts the lines of a whole file in the preset's format.
+func (p *presetSpec) sortLines(lines []string) ([]string, error) {
+	switch p.name {
+	case "goimports":
+		return sortBlocksIn(lines, importBlock, p.sortImports)
+	case "gomod":
+		return sortBlocksIn(lines, requireBlock, gomodGrammar.sortGroups)
+	case "gitignore":
+		return gitignoreGrammar.sortGroups(lines)
+	case "codeowners":
+		return codeownersGrammar.sortGroups(lines)
+	case "requirements":
+		return requirementsGrammar.sortGroups(lines)
+	}
+	return nil, errors.New("unknown preset " + p.name)
+}
+
+var (
+	importBlock  = regexp.MustCompile(`^\s*import\s*\(\s*$`)
+	requireBlock = regexp.MustCompile(`^\s*require\s*\(\s*$`)
+)
+
+// sortBlocksIn sorts the body of each parenthesized block opened by a
+// line matching start, leaving the rest of the lines alone.
+func sortBlocksIn(lines []string, start *regexp.Regexp, sortBody func([]string) ([]string, error)) ([]string, error) {
+	out := make([]string, 0, len(lines))
+	for i := 0; i < len(lines); i++ {
+		out = append(out, lines[i])
+		if !start.MatchString(lines[i]) {
+			continue
+		}
+		end := i + 1
+		for end < len(lines) && strings.TrimSpace(lines[end]) != ")" {
+			end++
+		}
+		if end == len(lines) {
+			return nil, fmt.Errorf("line %d: block is not closed", i+1)
+		}
+		body, err := sortBody(lines[i+1 : end])
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, body...)
+		out = append(out, lines[end])
+		i = end
+	}
+	return out, nil
+}
+
+// isGoComment reports whether a line holds only a Go comment.
+func isGoComment(line string) bool {
+	line = strings.TrimSpace(line)
+	return strings.HasPrefix(line, "//") || strings.HasPrefix(line, "/*")
+}
+
+// isHashComment reports whether a line holds only a # comment.
+func isHashComment(line string) bool {
+	return strings.HasPrefix(strings.TrimSpace(line), "#")
+}
+
+// importGrammar reads Go import specs, keyed by path and then name.
+var importGrammar = &grammar{
+	comment: isGoComment,
+	entry: func(line string) (string, int) {
+		path, name := importSpec(line)
+		return path + "\x00" + name, 0
+	},
+}
+
+// importSpec returns the path and name of an import spec line.
+func importSpec(line string) (path, name string) {
+	if i := strings.Index(line, "//"); i >= 0 {
+		line = line[:i]
+	}
+	f := strings.Fields(line)
+	switch len(f) {
+	case 0:
+		return "", ""
+	case 1:
+		return strings.Trim(f[0], "\"`"), ""
+	}
+	return strings.Trim(f[1], "\"`"), f[0]
+}
+
+// sortImports regroups the specs of an import block into the
+// standard library, other modules and the local prefixes, each group
+// sorted by path the way gofmt does. Comments go with the spec below
+// them.
+func (p *presetSpec) sortImports(lines []string) ([]string, error) {
+	var body []string
+	for _, line := range lines {
+		if strings.TrimSpace(line) != "" {
+			body = append(body, line)
+		}
+	}
+	_, entries, footer := importGrammar.parse(body)
+
+	groups := make([][]presetEntry, 3)
+	for _, e := range entries {
+		path := e.key[:strings.IndexByte(e.key, 0)]
+		g := 1
+		switch {
+		case p.isLocal(path):
+			g = 2
+		case !strings.Contains(strings.SplitN(path, "/", 2)[0], "."):
+			g = 0
+		}
+		groups[g] = append(groups[g], e)
+	}
+
+	out := make([]string, 0, len(lines))
+	for _, g := range groups {
+		if len(g) == 0 {
+			continue
+		}
+		if len(out) > 0 {
+			out = append(out, "")
+		}
+		if err := importGrammar.sortEntries(g); err != nil {
+			return nil, err
+		}
+		out = appendEntries(out, g)
+	}
+	return append(out, footer...), nil
+}
+
+// isLocal reports whether an import path is under a local prefix.
+func (p *presetSpec) isLocal(path string) bool {
+	for _, prefix := range p.local {
+		if path == prefix || strings.HasPrefix(path, strings.TrimSuffix(prefix, "/")+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// gomodGrammar reads the module requirements of a go.mod require
+// block, ordered by module path and then by version.
+var gomodGrammar = &grammar{
+	comment: isGoComment,
+	entry: func(line string) (string, int) {
+		if i := strings.Index(line, "//"); i >= 0 {
+			line = line[:i]
+		}
+		return strings.Join(strings.Fields(line), " "), 0
+	},
+	compare: func(a, b string) int {
+		pa, va := splitRequire(a)
+		pb, vb := splitRequire(b)
+		if c := strings.Compare(pa, pb); c != 0 {
+			return c
+		}
+		return compareVersions(va, vb)
+	},
+}
+
+func splitRequire(key string) (path, version string) {
+	if i := strings.IndexByte(key, ' '); i >= 0 {
+		return key[:i], key[i+1:]
+	}
+	return key, ""
+}
+
+// gitignoreGrammar reads .gitignore patterns. As the last pattern to
+// match a path decides whether it is ignored, patterns only move
+// among those of the same kind: a negation stays after every pattern
+// above it, and what it re-includes stays re-included.
+var gitignoreGrammar = &grammar{
+	comment: isHashComment,
+	headers: true,
+	entry: func(line string) (string, int) {
+		class := 0
+		if strings.HasPrefix(line, "!") {
+			class, line = 1, line[1:]
+		}
+		return strings.TrimPrefix(strings.TrimPrefix(line, `\`), "/"), class
+	},
+}
+
+// codeownersGrammar reads CODEOWNERS rules. The last rule to match a
+// file decides its owners, so only rules for plain paths from the top
+// of the repository move; sorted, a directory comes before the paths
+// in it, which keep their own owners. Rules with wildcards or that
+// match at any depth, and section headers, stay where they are.
+var codeownersGrammar = &grammar{
+	comment: isHashComment,
+	headers: true,
+	entry: func(line string) (string, int) {
+		f := strings.Fields(line)
+		if len(f) == 0 || strings.HasPrefix(f[0], "[") || strings.HasPrefix(f[0], "^[") {
+			return line, fixedClass
+		}
+		pattern := f[0]
+		if strings.ContainsAny(pattern, `*?[\`) || !strings.Contains(strings.TrimSuffix(pattern, "/"), "/") {
+			return line, fixedClass
+		}
+		return strings.TrimPrefix(pattern, "/"), 0
+	},
+	check: func(run []presetEntry) error {
+		for i, a := range run {
+			for _, b := range run[i+1:] {
+				if a.key != b.key && pathWithin(a.key, b.key) {
+					return fmt.Errorf("codeowners rule for /%s is overridden by the later one for /%s", a.key, b.key)
+				}
+			}
+		}
+		return nil
+	},
+}
+
+// pathWithin reports whether path lies inside the directory dir.
+func pathWithin(path, dir string) bool {
+	return strings.HasPrefix(path, strings.TrimSuffix(dir, "/")+"/")
+}
+
+// requirementsGrammar reads pip requirements files, ordered by the
+// normalized package name. Options such as -r and --index-url stay
+// where they are, and lines ending in a backslash keep the lines they
+// continue onto.
+var requirementsGrammar = &grammar{
+	comment: isHashComment,
+	headers: true,
+	continued: func(line string) bool {
+		return strings.HasSuffix(strings.TrimRight(line, " \t"), `\`)
+	},
+	entry: func(line string) (string, int) {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "-") {
+			return line, fixedClass
+		}
+		name := packageName.FindString(line)
+		return strings.ToLower(nameSeparators.ReplaceAllString(name, "-")) + "\x00" + line, 0
+	},
+}
+
+var (
+	packageName    = regexp.MustCompile(`^[A-Za-z0-9._-]+`)
+	nameSeparators = regexp.MustCompile(`[-_.]+`)
+)
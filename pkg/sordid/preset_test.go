@@ -0,0 +1,67 @@
+package sordid
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPresets(t *testing.T) {
+	tests := []struct {
+		opts []Option
+		in   string
+		want string
+	}{
+		{[]Option{Preset("goimports"), LocalImports("example.com/m")},
+			"package x\n\nimport (\n\t\"example.com/m/a\"\n\t\"os\"\n\n\t// Profiling.\n\t_ \"net/http/pprof\"\n\t\"github.com/b/b\"\n\t\"fmt\" // printing\n)\n",
+			"package x\n\nimport (\n\t\"fmt\" // printing\n\t// Profiling.\n\t_ \"net/http/pprof\"\n\t\"os\"\n\n\t\"github.com/b/b\"\n\n\t\"example.com/m/a\"\n)\n"},
+		{[]Option{Preset("gomod")},
+			"module m\n\nrequire (\n\tgolang.org/x/text v0.3.4\n\tgithub.com/b/b v1.10.0\n\t// Pinned.\n\tgithub.com/b/b v1.9.0 // indirect\n)\n",
+			"module m\n\nrequire (\n\t// Pinned.\n\tgithub.com/b/b v1.9.0 // indirect\n\tgithub.com/b/b v1.10.0\n\tgolang.org/x/text v0.3.4\n)\n"},
+		{[]Option{Preset("gitignore")},
+			"# Build\n/dist\n/bin\n!keep.o\ntmp/\nbuild/\n\n.vscode/\n# JetBrains\n.idea/\n",
+			"# Build\n/bin\n/dist\n!keep.o\nbuild/\ntmp/\n\n# JetBrains\n.idea/\n.vscode/\n"},
+		{[]Option{Preset("codeowners")},
+			"# Owners\n* @all\n/docs/ @docs\n/cmd/ @cmd\n/cmd/z/ @z\n/cmd/a/ @a\ndocs/ @w\n/b/ @b\n/a/ @a\n",
+			"# Owners\n* @all\n/cmd/ @cmd\n/cmd/a/ @a\n/cmd/z/ @z\n/docs/ @docs\ndocs/ @w\n/a/ @a\n/b/ @b\n"},
+		{[]Option{Preset("requirements")},
+			"# Runtime\n--index-url https://pypi.org/simple\nrequests==2.0\n# Pinned.\nattrs==1.0 \\\n    --hash=sha256:abc\nDjango_Extensions>=3\n",
+			"# Runtime\n--index-url https://pypi.org/simple\n# Pinned.\nattrs==1.0 \\\n    --hash=sha256:abc\nDjango_Extensions>=3\nrequests==2.0\n"},
+	}
+	for _, test := range tests {
+		s, err := New(test.opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got strings.Builder
+		if err := s.Sort(&got, strings.NewReader(test.in)); err != nil {
+			t.Fatal(err)
+		}
+		if got.String() != test.want {
+			t.Errorf("for %q expected %q, got %q", test.in, test.want, got.String())
+		}
+	}
+}
+
+func TestPresetErrors(t *testing.T) {
+	tests := []struct {
+		preset, in string
+	}{
+		{"codeowners", "/cmd/a/ @a\n/cmd/ @cmd\n"},
+		{"goimports", "import (\n\t\"fmt\"\n"},
+	}
+	for _, test := range tests {
+		s, err := New(Preset(test.preset))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got strings.Builder
+		if err := s.Sort(&got, strings.NewReader(test.in)); err == nil {
+			t.Errorf("expected an error sorting %q as %s", test.in, test.preset)
+		}
+	}
+	for _, opts := range [][]Option{{Preset("pom")}, {Preset("gitignore"), Unique()}, {Preset("gomod"), Top(1)}} {
+		if _, err := New(opts...); err == nil {
+			t.Errorf("expected an error from %d options", len(opts))
+		}
+	}
+}
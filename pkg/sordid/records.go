@@ -0,0 +1,371 @@
+package sordid
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// recordFormat is how the input is split into records and fields.
+type recordFormat int
+
+const (
+	formatLines recordFormat = iota
+	formatCSV                // RFC 4180 CSV, fields may be quoted and hold newlines
+	formatTSV                // one record per line, fields split on tabs
+	formatJSONL              // one JSON value per line
+)
+
+// recordFormats maps format names onto formats.
+var recordFormats = map[string]recordFormat{
+	"lines": formatLines,
+	"csv":   formatCSV,
+	"tsv":   formatTSV,
+	"jsonl": formatJSONL,
+}
+
+// parseFormat looks up a record format by name.
+func parseFormat(name string) (recordFormat, error) {
+	f, ok := recordFormats[name]
+	if !ok {
+		return formatLines, errors.New("unknown format: " + name)
+	}
+	return f, nil
+}
+
+// fieldKey is a sort key naming a column, by header name or number
+// from one, or a JSON path such as ".user.id".
+type fieldKey struct {
+	name string
+	keyOpts
+	hasOpts bool
+}
+
+// parseFieldKey reads a key such as "name", "3:n" or ".ts:r", where
+// the letters after the last colon are key options.
+func parseFieldKey(s string) (fieldKey, error) {
+	k := fieldKey{name: s}
+	if i := strings.LastIndexByte(s, ':'); i >= 0 {
+		var opts keySpec
+		if err := opts.applyOpts(s[i+1:], false); err == nil {
+			k.name, k.keyOpts, k.hasOpts = s[:i], opts.keyOpts, opts.hasOpts
+		}
+	}
+	if k.name == "" {
+		return k, errors.New("invalid field key: " + s)
+	}
+	return k, nil
+}
+
+// fieldList collects record keys given one at a time.
+type fieldList []fieldKey
+
+func (l *fieldList) String() string { return strconv.Itoa(len(*l)) + " fields" }
+
+func (l *fieldList) Set(s string) error {
+	k, err := parseFieldKey(s)
+	if err != nil {
+		return err
+	}
+	*l = append(*l, k)
+	return nil
+}
+
+// recordSpec sorts structured records instead of plain lines.
+type recordSpec struct {
+	format recordFormat
+	header bool // the first CSV or TSV record is a header and stays first
+	keys   []fieldKey
+}
+
+// newRecordSpec prepares the field keys, handing the global options to
+// those without their own.
+func newRecordSpec(format recordFormat, header bool, keys []fieldKey, global keyOpts) *recordSpec {
+	r := &recordSpec{format: format, header: header && format != formatJSONL}
+	for _, k := range keys {
+		k.resolve(global, k.hasOpts)
+		r.keys = append(r.keys, k)
+	}
+	if len(r.keys) == 0 {
+		// Without keys the whole record is the key.
+		whole := fieldKey{}
+		whole.resolve(global, false)
+		r.keys = []fieldKey{whole}
+	}
+	return r
+}
+
+// record is one unit of structured input.
+type record struct {
+	text   string   // the record as read, for line-based formats
+	fields []string // the parsed fields of CSV and TSV records
+	keys   []string // the extracted sort keys
+	index  int      // position in the input
+}
+
+// readRecords reads every input as records of the spec's format. The
+// header of the first input is returned separately; those of later
+// inputs are dropped. CRLF endings and a BOM on CSV input are noted
+// in format.
+func readRecords(inputs []*input, r *recordSpec, format *textFormat) (*record, []*record, error) {
+	var header *record
+	var recs []*record
+	for i, in := range inputs {
+		var part []*record
+		var err error
+		if r.format == formatCSV {
+			part, err = readCSV(in, format, i == 0)
+		} else {
+			part, err = readLineRecords(in, r.format)
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		if r.header && len(part) > 0 {
+			if i == 0 {
+				header = part[0]
+			}
+			part = part[1:]
+		}
+		recs = append(recs, part...)
+	}
+
+	for i, rec := range recs {
+		rec.index = i
+		if err := r.extractKeys(rec, header); err != nil {
+			return nil, nil, fmt.Errorf("record %d: %v", i+1, err)
+		}
+	}
+	return header, recs, nil
+}
+
+// readCSV parses a CSV input.
+func readCSV(in *input, format *textFormat, first bool) ([]*record, error) {
+	data, err := ioutil.ReadAll(in)
+	if err != nil {
+		return nil, err
+	}
+	if bytes.HasPrefix(data, utf8BOM) {
+		data = data[len(utf8BOM):]
+		if first {
+			format.bom = true
+		}
+	}
+	if first {
+		format.crlf = bytes.Contains(data, []byte("\r\n"))
+	}
+
+	cr := csv.NewReader(bytes.NewReader(data))
+	cr.FieldsPerRecord = -1
+	var recs []*record
+	for {
+		fields, err := cr.Read()
+		if err == io.EOF {
+			return recs, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		recs = append(recs, &record{fields: fields})
+	}
+}
+
+// readLineRecords reads an input holding one record per line.
+func readLineRecords(in *input, f recordFormat) ([]*record, error) {
+	var recs []*record
+	for in.scanner.Scan() {
+		rec := &record{text: in.scanner.Text()}
+		if f == formatTSV {
+			rec.fields = strings.Split(rec.text, "\t")
+		}
+		recs = append(recs, rec)
+	}
+	return recs, in.scanner.Err()
+}
+
+// extractKeys fills in the sort keys of rec.
+func (r *recordSpec) extractKeys(rec *record, header *record) error {
+	rec.keys = make([]string, len(r.keys))
+	for i := range r.keys {
+		k := &r.keys[i]
+		var v string
+		switch {
+		case k.name == "" && r.format == formatCSV:
+			v = strings.Join(rec.fields, ",")
+		case k.name == "":
+			v = rec.text
+		case r.format == formatJSONL:
+			var err error
+			if v, err = jsonPath(rec.text, k.name); err != nil {
+				return err
+			}
+		default:
+			col, err := column(k.name, header)
+			if err != nil {
+				return err
+			}
+			if col < len(rec.fields) {
+				v = rec.fields[col]
+			}
+		}
+		if k.skipStartBlanks {
+			v = strings.TrimLeft(v, " \t")
+		}
+		rec.keys[i] = v
+	}
+	return nil
+}
+
+// column finds a field by header name, or by number from one.
+func column(name string, header *record) (int, error) {
+	if header != nil {
+		for i, f := range header.fields {
+			if f == name {
+				return i, nil
+			}
+		}
+	}
+	n, err := strconv.Atoi(name)
+	if err != nil || n < 1 {
+		return 0, errors.New("unknown column: " + name)
+	}
+	return n - 1, nil
+}
+
+// jsonPath returns the value at a path such as ".user.id" or
+// ".items.0" in a line of JSON, as text.
+func jsonPath(line, path string) (string, error) {
+	if strings.TrimSpace(line) == "" {
+		return "", nil
+	}
+	dec := json.NewDecoder(strings.NewReader(line))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return "", err
+	}
+	return jsonText(jsonLookup(v, splitPath(path)))
+}
+
+// splitPath splits a path such as ".user.id" into its elements.
+func splitPath(path string) []string {
+	var parts []string
+	for _, part := range strings.Split(strings.TrimPrefix(path, "."), ".") {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return parts
+}
+
+// jsonLookup follows path into a decoded JSON value, returning nil
+// where it leads nowhere.
+func jsonLookup(v interface{}, path []string) interface{} {
+	for _, part := range path {
+		switch t := v.(type) {
+		case map[string]interface{}:
+			v = t[part]
+		case []interface{}:
+			i, err := strconv.Atoi(part)
+			if err != nil || i < 0 || i >= len(t) {
+				return nil
+			}
+			v = t[i]
+		default:
+			return nil
+		}
+	}
+	return v
+}
+
+// jsonText renders a decoded JSON value as a sort key. Strings give
+// their contents, numbers their literal, and null an empty key.
+func jsonText(v interface{}) (string, error) {
+	switch t := v.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return t, nil
+	case json.Number:
+		return t.String(), nil
+	case bool:
+		return strconv.FormatBool(t), nil
+	}
+	b, err := json.Marshal(v)
+	return string(b), err
+}
+
+// sortRecords orders records by the spec's keys, keeping ties in
+// input order.
+func (r *recordSpec) sortRecords(recs []*record) {
+	sort.SliceStable(recs, func(i, j int) bool {
+		for k := range r.keys {
+			key := &r.keys[k]
+			c := key.compare(recs[i].keys[k], recs[j].keys[k])
+			if c == 0 {
+				continue
+			}
+			if key.reverse {
+				return c > 0
+			}
+			return c < 0
+		}
+		return false
+	})
+}
+
+// recordsFiller writes records back out in their original format.
+func (r *recordSpec) recordsFiller(header *record, recs []*record, format *textFormat) func(w io.Writer) error {
+	if header != nil {
+		recs = append([]*record{header}, recs...)
+	}
+	if r.format != formatCSV {
+		lines := make([]string, len(recs))
+		for i, rec := range recs {
+			lines[i] = rec.text
+		}
+		return linesFiller(lines, format)
+	}
+
+	return func(w io.Writer) error {
+		if format.bom {
+			if _, err := w.Write(utf8BOM); err != nil {
+				return err
+			}
+		}
+		cw := csv.NewWriter(w)
+		cw.UseCRLF = format.crlf
+		for _, rec := range recs {
+			cw.Write(rec.fields) //nolint:errcheck
+		}
+		cw.Flush()
+		return cw.Error()
+	}
+}
+
+// recordLines renders records as display lines, for diffs.
+func (r *recordSpec) recordLines(header *record, recs []*record) []string {
+	if header != nil {
+		recs = append([]*record{header}, recs...)
+	}
+	lines := make([]string, 0, len(recs))
+	for _, rec := range recs {
+		if r.format != formatCSV {
+			lines = append(lines, rec.text)
+			continue
+		}
+		var b strings.Builder
+		cw := csv.NewWriter(&b)
+		cw.Write(rec.fields) //nolint:errcheck
+		cw.Flush()
+		lines = append(lines, strings.Split(strings.TrimSuffix(b.String(), "\n"), "\n")...)
+	}
+	return lines
+}
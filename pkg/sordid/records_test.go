@@ -0,0 +1,85 @@
+package sordid
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestSortRecords(t *testing.T) {
+	tests := []struct {
+		format recordFormat
+		header bool
+		keys   []string
+		in     string
+		want   string
+	}{
+		{
+			formatCSV, true, []string{"age:n"},
+			"name,age\r\n\"Smith, J\",40\r\n\"multi\nline\",9\r\nbob,100\r\n",
+			"name,age\r\n\"multi\r\nline\",9\r\n\"Smith, J\",40\r\nbob,100\r\n",
+		},
+		{
+			formatCSV, false, []string{"2:r", "1"},
+			"b,1\na,1\nc,2\n",
+			"c,2\na,1\nb,1\n",
+		},
+		{
+			formatTSV, true, []string{"city"},
+			"name\tcity\nx\tParis\ny\tBerlin\n",
+			"name\tcity\ny\tBerlin\nx\tParis\n",
+		},
+		{
+			formatJSONL, false, []string{".user.id:n", ".ts:r"},
+			`{"user":{"id":10},"ts":"2020-01-01"}` + "\n" +
+				`{"user":{"id":9},"ts":"2020-01-01"}` + "\n" +
+				`{"user":{"id":10},"ts":"2020-02-01"}` + "\n",
+			`{"user":{"id":9},"ts":"2020-01-01"}` + "\n" +
+				`{"user":{"id":10},"ts":"2020-02-01"}` + "\n" +
+				`{"user":{"id":10},"ts":"2020-01-01"}` + "\n",
+		},
+	}
+	for _, test := range tests {
+		var fields fieldList
+		for _, k := range test.keys {
+			if err := fields.Set(k); err != nil {
+				t.Fatal(err)
+			}
+		}
+		o := &options{
+			cmp:     newComparer(nil, "", keyOpts{}),
+			records: newRecordSpec(test.format, test.header, fields, keyOpts{}),
+		}
+		inputs := stringInputs(test.in)
+		var got strings.Builder
+		write := func(fill func(w io.Writer) error) error { return fill(&got) }
+		if err := sortInputs(inputs, o, inputs[0].format, write); err != nil {
+			t.Fatal(err)
+		}
+		if got.String() != test.want {
+			t.Errorf("keys %v: expected %q, got %q", test.keys, test.want, got.String())
+		}
+	}
+}
+
+func TestJSONPath(t *testing.T) {
+	line := `{"a":{"b":[1,{"c":"x"}]},"n":1.50,"t":true,"z":null}`
+	tests := map[string]string{
+		".a.b.1.c": "x",
+		".n":       "1.50",
+		".t":       "true",
+		".z":       "",
+		".missing": "",
+		".a.b.0":   "1",
+		".a.b.9":   "",
+	}
+	for path, want := range tests {
+		got, err := jsonPath(line, path)
+		if err != nil || got != want {
+			t.Error("For", path, "expected", want, "got", got, err)
+		}
+	}
+	if _, err := jsonPath("{broken", ".a"); err == nil {
+		t.Error("expected error for invalid JSON")
+	}
+}
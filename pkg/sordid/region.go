@@ -0,0 +1,175 @@
+package sordid
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// commentStyle is the syntax of a comment that can hold a region
+// marker, such as "#" or "<!--" ... "-->".
+type commentStyle struct {
+	open, close string
+}
+
+// commentStyles are the comment syntaxes recognised by default.
+var commentStyles = []commentStyle{
+	{"#", ""},
+	{"//", ""},
+	{"--", ""},
+	{"<!--", "-->"},
+}
+
+// parseCommentStyle reads a style such as "#" or "<!-- -->". An empty
+// string selects all of commentStyles.
+func parseCommentStyle(s string) ([]commentStyle, error) {
+	f := strings.Fields(s)
+	switch len(f) {
+	case 0:
+		return commentStyles, nil
+	case 1:
+		return []commentStyle{{f[0], ""}}, nil
+	case 2:
+		return []commentStyle{{f[0], f[1]}}, nil
+	}
+	return nil, errors.New("invalid comment style: " + s)
+}
+
+// regionSpec limits sorting to the lines between "<marker> start"
+// and "<marker> end" comments, leaving the rest of the file alone.
+type regionSpec struct {
+	marker   string
+	comments []commentStyle
+
+	groups       bool // blank lines split a region into separately sorted groups
+	continuation bool // lines indented past an item's first line move with it
+}
+
+// markerKind is what a line is to the region scanner.
+type markerKind int
+
+const (
+	notMarker markerKind = iota
+	startMarker
+	endMarker
+)
+
+// kind reports whether line holds a start or end marker comment.
+func (r *regionSpec) kind(line string) markerKind {
+	for _, c := range r.comments {
+		i := strings.Index(line, c.open)
+		if i < 0 {
+			continue
+		}
+		body := line[i+len(c.open):]
+		if c.close != "" {
+			j := strings.Index(body, c.close)
+			if j < 0 {
+				continue
+			}
+			body = body[:j]
+		}
+		f := strings.Fields(body)
+		if len(f) < 2 || f[0] != r.marker {
+			continue
+		}
+		switch f[1] {
+		case "start":
+			return startMarker
+		case "end":
+			return endMarker
+		}
+	}
+	return notMarker
+}
+
+// sortRegions sorts the lines inside each marked region and returns
+// the whole file with everything outside the regions untouched.
+func sortRegions(lines []string, o *options) ([]string, error) {
+	r := o.region
+	out := make([]string, 0, len(lines))
+	start := -1
+	for i, line := range lines {
+		switch r.kind(line) {
+		case startMarker:
+			if start >= 0 {
+				return nil, fmt.Errorf("line %d: %s start inside region from line %d", i+1, r.marker, start)
+			}
+			out = append(out, line)
+			start = i + 1
+		case endMarker:
+			if start < 0 {
+				return nil, fmt.Errorf("line %d: %s end without start", i+1, r.marker)
+			}
+			out = append(out, sortRegion(lines[start:i], o)...)
+			out = append(out, line)
+			start = -1
+		default:
+			if start < 0 {
+				out = append(out, line)
+			}
+		}
+	}
+	if start >= 0 {
+		return nil, fmt.Errorf("line %d: %s start without end", start, r.marker)
+	}
+	return out, nil
+}
+
+// sortRegion sorts the body of one region.
+func sortRegion(lines []string, o *options) []string {
+	if !o.region.groups {
+		return sortItems(lines, o)
+	}
+
+	out := make([]string, 0, len(lines))
+	group := 0
+	for i, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			out = append(out, sortItems(lines[group:i], o)...)
+			out = append(out, line)
+			group = i + 1
+		}
+	}
+	return append(out, sortItems(lines[group:], o)...)
+}
+
+// sortItems sorts lines as items, each made of a line plus, with
+// continuation set, the more deeply indented lines that follow it.
+func sortItems(lines []string, o *options) []string {
+	var items [][]string
+	for _, line := range lines {
+		if o.region.continuation && len(items) > 0 && strings.TrimSpace(line) != "" {
+			first := items[len(items)-1][0]
+			if indent(line) > indent(first) {
+				items[len(items)-1] = append(items[len(items)-1], line)
+				continue
+			}
+		}
+		items = append(items, []string{line})
+	}
+
+	sort.SliceStable(items, func(i, j int) bool { return o.cmp.less(items[i][0], items[j][0]) })
+
+	if o.filtered() {
+		out := &sliceSink{}
+		f := o.filter(out)
+		for _, item := range items {
+			addItem(f, item)
+		}
+		f.flush() //nolint:errcheck
+		return out.lines
+	}
+
+	out := make([]string, 0, len(lines))
+	for _, item := range items {
+		out = append(out, item...)
+	}
+	return out
+}
+
+// indent counts the leading blanks of line.
+func indent(line string) int {
+	return skipBlanks(line, 0)
+}
@@ -0,0 +1,66 @@
+package sordid
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSortRegions(t *testing.T) {
+	tests := []struct {
+		spec regionSpec
+		in   string
+		want string
+	}{
+		{
+			regionSpec{},
+			"z\n# keep-sorted start\nc\na\nb\n# keep-sorted end\ny\nx",
+			"z\n# keep-sorted start\na\nb\nc\n# keep-sorted end\ny\nx",
+		},
+		{
+			regionSpec{},
+			"import (\n\t// keep-sorted start\n\t\"os\"\n\t\"fmt\"\n\t// keep-sorted end\n)",
+			"import (\n\t// keep-sorted start\n\t\"fmt\"\n\t\"os\"\n\t// keep-sorted end\n)",
+		},
+		{
+			regionSpec{},
+			"<!-- keep-sorted start -->\n- b\n- a\n<!-- keep-sorted end -->\n-- keep-sorted start\nd\nc\n-- keep-sorted end",
+			"<!-- keep-sorted start -->\n- a\n- b\n<!-- keep-sorted end -->\n-- keep-sorted start\nc\nd\n-- keep-sorted end",
+		},
+		{
+			regionSpec{groups: true},
+			"# keep-sorted start\nb\na\n\nd\nc\n# keep-sorted end",
+			"# keep-sorted start\na\nb\n\nc\nd\n# keep-sorted end",
+		},
+		{
+			regionSpec{continuation: true},
+			"# keep-sorted start\n- name: b\n  value: 2\n- name: a\n  value: 1\n# keep-sorted end",
+			"# keep-sorted start\n- name: a\n  value: 1\n- name: b\n  value: 2\n# keep-sorted end",
+		},
+	}
+	cmp := newComparer(nil, "", keyOpts{})
+	for _, test := range tests {
+		r := test.spec
+		r.marker, r.comments = "keep-sorted", commentStyles
+		got, err := sortRegions(strings.Split(test.in, "\n"), &options{cmp: cmp, region: &r})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Join(got, "\n") != test.want {
+			t.Errorf("for %q expected %q, got %q", test.in, test.want, strings.Join(got, "\n"))
+		}
+	}
+}
+
+func TestSortRegionsErrors(t *testing.T) {
+	r := &regionSpec{marker: "keep-sorted", comments: commentStyles}
+	cmp := newComparer(nil, "", keyOpts{})
+	for _, in := range []string{
+		"# keep-sorted start\na",
+		"a\n# keep-sorted end",
+		"# keep-sorted start\n# keep-sorted start\n# keep-sorted end",
+	} {
+		if _, err := sortRegions(strings.Split(in, "\n"), &options{cmp: cmp, region: r}); err == nil {
+			t.Errorf("expected error for %q", in)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package sordid
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Rewrite controls how Sorter.Rewrite treats a file it sorts in place.
+type Rewrite struct {
+	// Backup, if set, is a suffix such as ".bak": the original file
+	// is kept under its name plus the suffix before being replaced.
+	Backup string
+
+	// Diff, if set, receives a unified diff of each change.
+	Diff io.Writer
+
+	// DryRun leaves the file alone, only reporting what would change.
+	DryRun bool
+}
+
+// Rewrite sorts the file at path in place as SortFile does, reporting
+// whether that changes it. A file that CheckFile finds sorted already
+// is left untouched, even where sorting would write it out differently.
+// Showing a diff or doing a dry run holds the file and its sorted form
+// in memory.
+func (s *Sorter) Rewrite(path string, r Rewrite) (bool, error) {
+	sorted, err := checkFile(path, s.o, false, ioutil.Discard)
+	if err != nil || sorted {
+		return false, err
+	}
+	if r.Diff == nil && !r.DryRun {
+		if r.Backup != "" {
+			if err := backupFile(path, r.Backup); err != nil {
+				return false, err
+			}
+		}
+		return true, sortFile(path, s.o)
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return false, err
+	}
+	in := newInput(ioutil.NopCloser(bytes.NewReader(data)), s.o.nul)
+	var out bytes.Buffer
+	err = sortInputs([]*input{in}, s.o, in.format, func(fill func(w io.Writer) error) error {
+		return fill(&out)
+	})
+	if err != nil {
+		return false, err
+	}
+
+	if r.Diff != nil {
+		err := unifiedDiff(r.Diff, path, path+" (sorted)", splitLines(data, s.o.nul), splitLines(out.Bytes(), s.o.nul))
+		if err != nil {
+			return true, err
+		}
+	}
+	if r.DryRun {
+		return true, nil
+	}
+	if r.Backup != "" {
+		if err := backupFile(path, r.Backup); err != nil {
+			return true, err
+		}
+	}
+	return true, atomicWrite(path, func(w io.Writer) error {
+		_, err := w.Write(out.Bytes())
+		return err
+	})
+}
+
+// splitLines splits data into its lines, or NUL-terminated records.
+func splitLines(data []byte, nul bool) []string {
+	var lines []string
+	s := newLineScanner(bytes.NewReader(data), &textFormat{nul: nul})
+	for s.Scan() {
+		lines = append(lines, s.Text())
+	}
+	return lines
+}
+
+// backupFile keeps the current contents of the file at path under its
+// name plus suffix. The backup is a hard link where possible, which
+// atomicWrite leaves holding the original once it replaces the file.
+// A file whose name ends in suffix is a backup itself and is refused,
+// as backing it up would bury the original one level deeper.
+func backupFile(path, suffix string) error {
+	target, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return err
+	}
+	if strings.HasSuffix(path, suffix) || strings.HasSuffix(target, suffix) {
+		return fmt.Errorf("%s is a %s backup, not backing it up again", path, suffix)
+	}
+	backup := target + suffix
+	if err := os.Remove(backup); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if os.Link(target, backup) == nil {
+		return nil
+	}
+
+	info, err := os.Stat(target)
+	if err != nil {
+		return err
+	}
+	src, err := os.Open(target)
+	if err != nil {
+		return err
+	}
+	defer src.Close() //nolint:errcheck
+	dst, err := os.OpenFile(backup, os.O_WRONLY|os.O_CREATE|os.O_EXCL, info.Mode())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close() //nolint:errcheck
+		return err
+	}
+	return dst.Close()
+}
+
+// Restore puts back the backup of the file at path that Rewrite made
+// with the given suffix, removing the backup.
+func Restore(path, suffix string) error {
+	target, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return err
+	}
+	return os.Rename(target+suffix, target)
+}
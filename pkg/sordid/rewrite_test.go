@@ -0,0 +1,120 @@
+package sordid
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRewrite(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sordid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	s, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		in      string
+		rw      Rewrite
+		changed bool
+		want    string
+		diff    string
+		backup  bool
+	}{
+		{"a\nb\n", Rewrite{Backup: ".bak"}, false, "a\nb\n", "", false},
+		{"b\na\n", Rewrite{Backup: ".bak"}, true, "a\nb\n", "", true},
+		{"b\na\n", Rewrite{DryRun: true}, true, "b\na\n", "--- f\n+++ f (sorted)\n@@ -1,2 +1,2 @@\n-b\n a\n+b\n", false},
+		{"b\na\n", Rewrite{Backup: "~"}, true, "a\nb\n", "--- f\n+++ f (sorted)\n@@ -1,2 +1,2 @@\n-b\n a\n+b\n", true},
+	}
+	for i, test := range tests {
+		path := filepath.Join(dir, "f")
+		if err := ioutil.WriteFile(path, []byte(test.in), 0640); err != nil {
+			t.Fatal(err)
+		}
+		var diff strings.Builder
+		if test.diff != "" {
+			test.rw.Diff = &diff
+		}
+		changed, err := s.Rewrite(path, test.rw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if changed != test.changed || string(got) != test.want {
+			t.Errorf("%d: expected %v %q, got %v %q", i, test.changed, test.want, changed, got)
+		}
+		if got := strings.Replace(diff.String(), path, "f", -1); got != test.diff {
+			t.Errorf("%d: expected diff %q, got %q", i, test.diff, got)
+		}
+
+		backup := path + test.rw.Backup
+		old, err := ioutil.ReadFile(backup)
+		if test.backup {
+			if err != nil || string(old) != test.in {
+				t.Errorf("%d: expected backup %q, got %q, %v", i, test.in, old, err)
+			}
+			if err := Restore(path, test.rw.Backup); err != nil {
+				t.Fatal(err)
+			}
+			if got, _ := ioutil.ReadFile(path); string(got) != test.in {
+				t.Errorf("%d: expected restored %q, got %q", i, test.in, got)
+			}
+		} else if test.rw.Backup != "" && !os.IsNotExist(err) {
+			t.Errorf("%d: expected no backup, got %v", i, err)
+		}
+	}
+}
+
+// Files that check as sorted are left alone however they would be
+// written out, with or without a diff or dry run.
+func TestRewriteSortedFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sordid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		in   string
+		opts []Option
+	}{
+		{"a\r\nb\n", nil},
+		{"\"a\",1\nb,2\n", []Option{Format("csv"), NoHeader()}},
+	}
+	for i, test := range tests {
+		s, err := New(test.opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, rw := range []Rewrite{{}, {DryRun: true}, {Diff: ioutil.Discard}} {
+			path := filepath.Join(dir, "f")
+			if err := ioutil.WriteFile(path, []byte(test.in), 0640); err != nil {
+				t.Fatal(err)
+			}
+			var diff strings.Builder
+			if rw.Diff != nil || rw.DryRun {
+				rw.Diff = &diff
+			}
+			changed, err := s.Rewrite(path, rw)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := ioutil.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if changed || string(got) != test.in || diff.Len() != 0 {
+				t.Errorf("%d %+v: expected %q left alone, got %v %q and diff %q", i, rw, test.in, changed, got, diff.String())
+			}
+		}
+	}
+}
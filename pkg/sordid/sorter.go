@@ -0,0 +1,602 @@
+// Package sordid sorts lines and records of text the way the sordid
+// command does: by sort(1)-style keys in a choice of comparison modes,
+// in memory or spilling to disk, keeping the order of equal lines, and
+// rewriting files in place atomically.
+package sordid
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"strings"
+	"time"
+)
+
+// Sorter sorts text according to the options it was created with.
+// A Sorter is not safe for concurrent use.
+type Sorter struct {
+	o *options
+}
+
+// settings gathers the options given to New before they are resolved.
+type settings struct {
+	keys   keyList
+	sep    string
+	global keyOpts
+	mode   string
+
+	memLimit int64
+	tmpDir   string
+	nul      bool
+	workers  int
+
+	regions         bool
+	marker, comment string
+	groups, contin  bool
+	dedupe          *dedupeSpec
+	collapse        bool
+	pick            *pickSpec
+	preset          string
+	local           []string
+
+	format string
+	fields fieldList
+	header bool
+	arrays arrayRules
+
+	paragraphs  bool
+	recordSep   string
+	recordStart string
+	recordKey   string
+}
+
+// Option configures a Sorter.
+type Option func(*settings) error
+
+// New returns a Sorter configured by opts. Without options it orders
+// whole lines byte by byte.
+func New(opts ...Option) (*Sorter, error) {
+	s := &settings{header: true, workers: 1}
+	for _, opt := range opts {
+		if err := opt(s); err != nil {
+			return nil, err
+		}
+	}
+
+	global := s.global
+	global.skipEndBlanks = global.skipStartBlanks
+	if s.mode != "" {
+		var err error
+		if global.mode, err = parseMode(s.mode); err != nil {
+			return nil, err
+		}
+	}
+	if global.locale != "" && global.mode == modeLexical {
+		global.mode = modeCollate
+	}
+
+	o := &options{
+		cmp:      newComparer(s.keys, s.sep, global),
+		memLimit: s.memLimit,
+		tmpDir:   s.tmpDir,
+		nul:      s.nul,
+		workers:  s.workers,
+		dedupe:   s.dedupe,
+		pick:     s.pick,
+	}
+	if s.collapse {
+		k := o.cmp.cidrKey()
+		if k == nil || k.reverse {
+			return nil, errors.New("collapsing CIDR blocks needs a key in ascending cidr mode")
+		}
+		o.collapse = true
+	}
+	if s.pick != nil && (s.dedupe != nil || s.collapse) {
+		return nil, errors.New("picking lines cannot be combined with dropping duplicates or collapsing")
+	}
+	if s.regions {
+		comments, err := parseCommentStyle(s.comment)
+		if err != nil {
+			return nil, err
+		}
+		o.region = &regionSpec{
+			marker:       s.marker,
+			comments:     comments,
+			groups:       s.groups,
+			continuation: s.contin,
+		}
+	}
+	if d, ok := documentFormats[s.format]; ok {
+		o.document = &documentSpec{format: d, arrays: s.arrays}
+	} else if s.format != "" {
+		format, err := parseFormat(s.format)
+		if err != nil {
+			return nil, err
+		}
+		if format != formatLines {
+			o.records = newRecordSpec(format, s.header, s.fields, global)
+		}
+	}
+	if s.paragraphs || s.recordSep != "" || s.recordStart != "" {
+		var err error
+		if o.blocks, err = newBlockSpec(s.paragraphs, s.recordSep, s.recordStart, s.recordKey); err != nil {
+			return nil, err
+		}
+	}
+	if s.preset != "" {
+		if o.wholeFile() || o.pick != nil || o.filtered() {
+			return nil, errors.New("a preset cannot be combined with regions, records, documents, picking or dropping lines")
+		}
+		o.preset = &presetSpec{name: s.preset, local: s.local}
+	}
+	if o.filtered() && (o.records != nil || o.blocks != nil || o.document != nil) {
+		return nil, errors.New("dropping duplicates and collapsing apply to lines and regions only, not records or documents")
+	}
+	if o.pick != nil && o.wholeFile() {
+		return nil, errors.New("top, bottom, shuffle and sample pick lines only, not regions, records or documents")
+	}
+	return &Sorter{o: o}, nil
+}
+
+// Key adds a sort key such as "2,2n" or "1.3,1.5r", in the syntax of
+// sort -k. Keys apply in the order given.
+func Key(spec string) Option {
+	return func(s *settings) error { return s.keys.Set(spec) }
+}
+
+// Separator splits fields at sep instead of at runs of blanks. The
+// two-character escapes `\t` and `\0` stand for tab and NUL.
+func Separator(sep string) Option {
+	return func(s *settings) error {
+		s.sep = parseSeparator(sep)
+		return nil
+	}
+}
+
+// Mode sets the comparison mode by name: lexical, numeric, general,
+// human, version, natural, collate, time, ip, cidr or host.
+func Mode(name string) Option {
+	return func(s *settings) error {
+		_, err := parseMode(name)
+		s.mode = name
+		return err
+	}
+}
+
+// Locale collates by the Unicode Collation Algorithm for a language
+// such as "de" or "sv", implying the collate mode.
+func Locale(tag string) Option {
+	return func(s *settings) error {
+		var err error
+		s.global.locale, err = parseLocale(tag)
+		return err
+	}
+}
+
+// TimeLayout reads keys in the time mode with a Go time layout, or
+// one of the named layouts rfc3339, syslog, apache and epoch. Without
+// it the layout of each key is detected.
+func TimeLayout(layout string) Option {
+	return func(s *settings) error {
+		var err error
+		s.global.timeLayout, err = parseTimeLayout(layout)
+		return err
+	}
+}
+
+// TimeZone reads times that carry no zone of their own in the named
+// location, such as "Local" or "Europe/Berlin", rather than in UTC.
+func TimeZone(name string) Option {
+	return func(s *settings) error {
+		var err error
+		s.global.timeZone, err = time.LoadLocation(name)
+		return err
+	}
+}
+
+// StrictTime makes a key in the time mode that cannot be parsed an
+// error, rather than sorting it after every time.
+func StrictTime() Option {
+	return func(s *settings) error {
+		s.global.strictTime = true
+		return nil
+	}
+}
+
+// Reverse reverses the order of keys without options of their own.
+func Reverse() Option {
+	return func(s *settings) error {
+		s.global.reverse = true
+		return nil
+	}
+}
+
+// SkipBlanks ignores leading and trailing blanks in keys.
+func SkipBlanks() Option {
+	return func(s *settings) error {
+		s.global.skipStartBlanks = true
+		return nil
+	}
+}
+
+// FoldCase ignores case.
+func FoldCase() Option {
+	return func(s *settings) error {
+		s.global.foldCase = true
+		return nil
+	}
+}
+
+// FoldAccents ignores accents and other diacritics.
+func FoldAccents() Option {
+	return func(s *settings) error {
+		s.global.foldAccents = true
+		return nil
+	}
+}
+
+// Dictionary considers only blanks, letters and digits.
+func Dictionary() Option {
+	return func(s *settings) error {
+		s.global.dictionary = true
+		return nil
+	}
+}
+
+// Printable considers only printable characters.
+func Printable() Option {
+	return func(s *settings) error {
+		s.global.printable = true
+		return nil
+	}
+}
+
+// MemoryLimit caps the bytes of lines held in memory; larger inputs
+// spill sorted runs to disk. Zero means no limit.
+func MemoryLimit(bytes int64) Option {
+	return func(s *settings) error {
+		s.memLimit = bytes
+		return nil
+	}
+}
+
+// TempDir puts spilled runs in dir instead of the system temp dir.
+func TempDir(dir string) Option {
+	return func(s *settings) error {
+		s.tmpDir = dir
+		return nil
+	}
+}
+
+// NUL reads and writes records ending in NUL rather than newline.
+func NUL() Option {
+	return func(s *settings) error {
+		s.nul = true
+		return nil
+	}
+}
+
+// Parallel sorts on up to n goroutines at once.
+func Parallel(n int) Option {
+	return func(s *settings) error {
+		s.workers = n
+		return nil
+	}
+}
+
+// Regions sorts only the lines between "<marker> start" and
+// "<marker> end" comments; an empty marker means "keep-sorted".
+// comment gives the comment syntax, such as "#" or "<!-- -->"; empty
+// accepts #, //, -- and <!-- -->.
+func Regions(marker, comment string) Option {
+	return func(s *settings) error {
+		if marker == "" {
+			marker = "keep-sorted"
+		}
+		s.regions, s.marker, s.comment = true, marker, comment
+		return nil
+	}
+}
+
+// RegionGroups sorts blank-line separated groups within a region
+// independently.
+func RegionGroups() Option {
+	return func(s *settings) error {
+		s.groups = true
+		return nil
+	}
+}
+
+// RegionContinuation keeps lines indented more deeply than the first
+// line of a region item with that line.
+func RegionContinuation() Option {
+	return func(s *settings) error {
+		s.contin = true
+		return nil
+	}
+}
+
+// Unique drops lines whose keys equal an earlier line's. It and the
+// other options dropping lines apply to lines and regions; New refuses
+// them for records and documents.
+func Unique() Option {
+	return func(s *settings) error {
+		s.dedupeSpec()
+		return nil
+	}
+}
+
+// Keep chooses which of a run of equal lines Unique keeps: "first" or
+// "last". Keeping the last implies Unique.
+func Keep(which string) Option {
+	return func(s *settings) error {
+		last, err := parseKeep(which)
+		if last {
+			s.dedupeSpec().keepLast = true
+		}
+		return err
+	}
+}
+
+// Count drops duplicates and prefixes each line with its number of
+// occurrences, like uniq -c.
+func Count() Option {
+	return func(s *settings) error {
+		s.dedupeSpec().count = true
+		return nil
+	}
+}
+
+// OnlyDuplicates outputs one copy of each duplicated line only, like
+// uniq -d.
+func OnlyDuplicates() Option {
+	return func(s *settings) error {
+		s.dedupeSpec().onlyDups = true
+		return nil
+	}
+}
+
+// OnlySingles outputs only lines that have no duplicates, like uniq -u.
+func OnlySingles() Option {
+	return func(s *settings) error {
+		s.dedupeSpec().onlySingles = true
+		return nil
+	}
+}
+
+// CollapseCIDRs drops lines whose CIDR block lies within the block of
+// an earlier line, once sorted by a key in the cidr mode.
+func CollapseCIDRs() Option {
+	return func(s *settings) error {
+		s.collapse = true
+		return nil
+	}
+}
+
+// dedupeSpec returns the dedupe options, creating them on first use.
+func (s *settings) dedupeSpec() *dedupeSpec {
+	if s.dedupe == nil {
+		s.dedupe = &dedupeSpec{}
+	}
+	return s.dedupe
+}
+
+// Top outputs only the n lines that sort last, greatest first, as
+// sorting in reverse and keeping the first n would. At most n lines are
+// held in memory, however large the input.
+func Top(n int) Option {
+	return pickOption(&pickSpec{kind: pickTop, n: n})
+}
+
+// Bottom outputs only the n lines that sort first, as sorting and
+// keeping the first n would. At most n lines are held in memory.
+func Bottom(n int) Option {
+	return pickOption(&pickSpec{kind: pickBottom, n: n})
+}
+
+// Shuffle outputs the lines in a random order that the same seed
+// always reproduces, ignoring the sort keys.
+func Shuffle(seed int64) Option {
+	return pickOption(&pickSpec{kind: pickShuffle, seed: seed})
+}
+
+// Sample outputs n lines chosen at random by reservoir sampling, in
+// the order of the input; the same seed picks the same lines. At most
+// n lines are held in memory.
+func Sample(n int, seed int64) Option {
+	return pickOption(&pickSpec{kind: pickSample, n: n, seed: seed})
+}
+
+// pickOption returns an Option that picks lines as p says. Only one
+// way of picking may be given.
+func pickOption(p *pickSpec) Option {
+	return func(s *settings) error {
+		if p.n < 0 {
+			return errors.New("cannot pick a negative number of lines")
+		}
+		if s.pick != nil {
+			return errors.New("only one of top, bottom, shuffle and sample may be given")
+		}
+		s.pick = p
+		return nil
+	}
+}
+
+// Preset sorts files of a format whose lines come in groups and with
+// comments, keeping each comment with the line below it:
+//
+//	goimports     the import blocks of Go source, regrouped into the
+//	              standard library, other modules and local imports
+//	gomod         the require blocks of a go.mod file
+//	gitignore     .gitignore patterns, never moving one past a
+//	              pattern of the other kind, so negations keep working
+//	codeowners    the rules for plain paths of a CODEOWNERS file
+//	requirements  a pip requirements file, by package name
+//
+// Blank lines split the groups sorted, and the comments opening a
+// group stay as its header, except in Go imports and go.mod.
+// Presets order lines by their own keys, not by Key or Mode.
+func Preset(name string) Option {
+	return func(s *settings) error {
+		s.preset = name
+		return parsePreset(name)
+	}
+}
+
+// LocalImports puts Go imports under any of the comma-separated
+// prefixes in a group of their own after all others, like
+// goimports -local.
+func LocalImports(prefixes string) Option {
+	return func(s *settings) error {
+		for _, p := range strings.Split(prefixes, ",") {
+			if p = strings.TrimSpace(p); p != "" {
+				s.local = append(s.local, p)
+			}
+		}
+		return nil
+	}
+}
+
+// Format reads the input as lines, csv, tsv or jsonl records, or as
+// json or yaml documents to canonicalize.
+func Format(name string) Option {
+	return func(s *settings) error {
+		s.format = name
+		if _, ok := documentFormats[name]; ok {
+			return nil
+		}
+		_, err := parseFormat(name)
+		return err
+	}
+}
+
+// Array sorts the arrays of a json or yaml document found at a path,
+// such as ".users" or ".groups.*.members", by a field of their
+// elements given after an equals sign, as in ".users=.name". Without
+// a field, elements are sorted by their own value.
+func Array(rule string) Option {
+	return func(s *settings) error { return s.arrays.Set(rule) }
+}
+
+// By adds a record key: a column name or number, or a JSON path such
+// as ".user.id", optionally followed by a colon and key options, as
+// in "port:n".
+func By(field string) Option {
+	return func(s *settings) error { return s.fields.Set(field) }
+}
+
+// NoHeader sorts the first CSV or TSV record with the rest instead of
+// keeping it in place as a header.
+func NoHeader() Option {
+	return func(s *settings) error {
+		s.header = false
+		return nil
+	}
+}
+
+// Paragraphs sorts blank-line separated paragraphs as units.
+func Paragraphs() Option {
+	return func(s *settings) error {
+		s.paragraphs = true
+		return nil
+	}
+}
+
+// RecordSeparator sorts multi-line records separated by lines matching
+// the regular expression re.
+func RecordSeparator(re string) Option {
+	return func(s *settings) error {
+		s.recordSep = re
+		return nil
+	}
+}
+
+// RecordStart sorts multi-line records that each begin with a line
+// matching the regular expression re.
+func RecordStart(re string) Option {
+	return func(s *settings) error {
+		s.recordStart = re
+		return nil
+	}
+}
+
+// RecordKey sorts multi-line records by the first submatch of the
+// regular expression re instead of by their first line.
+func RecordKey(re string) Option {
+	return func(s *settings) error {
+		s.recordKey = re
+		return nil
+	}
+}
+
+// Compare returns -1, 0 or 1 as line a sorts before, with or after b.
+func (s *Sorter) Compare(a, b string) int {
+	return s.o.cmp.compare(a, b)
+}
+
+// SortLines returns lines in sorted order, leaving lines untouched.
+func (s *Sorter) SortLines(lines []string) ([]string, error) {
+	if s.o.records != nil {
+		return nil, errors.New("structured records cannot be sorted as lines")
+	}
+	return sortLines(lines, s.o)
+}
+
+// Sort reads all of rs and writes their lines sorted together to w.
+func (s *Sorter) Sort(w io.Writer, rs ...io.Reader) error {
+	return sortInputs(s.readerInputs(rs), s.o, &textFormat{nul: s.o.nul, finalNewline: true}, fillWriter(w))
+}
+
+// Merge merges rs, each sorted already, into w without sorting them
+// again.
+func (s *Sorter) Merge(w io.Writer, rs ...io.Reader) error {
+	return mergeInputs(s.readerInputs(rs), s.o, &textFormat{nul: s.o.nul, finalNewline: true}, fillWriter(w))
+}
+
+func (s *Sorter) readerInputs(rs []io.Reader) []*input {
+	inputs := make([]*input, 0, len(rs))
+	for _, r := range rs {
+		inputs = append(inputs, newInput(ioutil.NopCloser(r), s.o.nul))
+	}
+	return inputs
+}
+
+// fillWriter returns the writeFunc producing output on w.
+func fillWriter(w io.Writer) writeFunc {
+	return func(fill func(w io.Writer) error) error { return fill(w) }
+}
+
+// SortFile sorts the file at path in place, keeping its byte order
+// mark, line endings and final newline, and replacing it atomically.
+func (s *Sorter) SortFile(path string) error {
+	return sortFile(path, s.o)
+}
+
+// SortFiles sorts the named files together into output. The name
+// Stdio stands for standard input or output; an existing output file
+// is replaced atomically.
+func (s *Sorter) SortFiles(output string, paths ...string) error {
+	return s.files(sortInputs, output, paths)
+}
+
+// MergeFiles merges the named files, each sorted already, into output
+// as SortFiles does.
+func (s *Sorter) MergeFiles(output string, paths ...string) error {
+	return s.files(mergeInputs, output, paths)
+}
+
+func (s *Sorter) files(sortAll func([]*input, *options, *textFormat, writeFunc) error, output string, paths []string) error {
+	inputs, err := openInputs(paths, s.o.nul)
+	if err != nil {
+		return err
+	}
+	defer closeInputs(inputs)
+	return sortAll(inputs, s.o, &textFormat{nul: s.o.nul, finalNewline: true}, writeOutput(output))
+}
+
+// CheckFile reports whether the file at path is sorted already. If it
+// is not, the first line out of order is reported on w, followed with
+// diff set by a unified diff of what sorting would change.
+func (s *Sorter) CheckFile(path string, w io.Writer, diff bool) (bool, error) {
+	return checkFile(path, s.o, diff, w)
+}
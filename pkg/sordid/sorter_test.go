@@ -0,0 +1,77 @@
+package sordid
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestSorter(t *testing.T) {
+	tests := []struct {
+		opts []Option
+		in   []string
+		want string
+	}{
+		{nil, []string{"b\na\n", "c\n"}, "a\nb\nc\n"},
+		{[]Option{Key("2,2"), Mode("numeric"), Reverse()}, []string{"x 2\ny 10\n", "z 1"}, "y 10\nx 2\nz 1\n"},
+		{[]Option{FoldCase(), Unique()}, []string{"B\nb\na\n"}, "a\nB\n"},
+		{[]Option{Format("csv"), By("n:n")}, []string{"name,n\nb,10\na,9\n"}, "name,n\na,9\nb,10\n"},
+		{[]Option{Regions("", "#")}, []string{"z\n# keep-sorted start\nb\na\n# keep-sorted end\ny\n"},
+			"z\n# keep-sorted start\na\nb\n# keep-sorted end\ny\n"},
+		{[]Option{Regions("", "#"), Mode("cidr"), CollapseCIDRs()},
+			[]string{"# keep-sorted start\n10.0.0.0/24\n10.0.0.0/8\n192.168.0.0/16\n# keep-sorted end\n"},
+			"# keep-sorted start\n10.0.0.0/8\n192.168.0.0/16\n# keep-sorted end\n"},
+		{[]Option{Regions("", "#"), RegionContinuation(), Mode("cidr"), CollapseCIDRs()},
+			[]string{"# keep-sorted start\n10.0.0.0/24\n  office\n10.0.0.0/8\n  all\n# keep-sorted end\n"},
+			"# keep-sorted start\n10.0.0.0/8\n  all\n# keep-sorted end\n"},
+		{[]Option{Mode("version"), Parallel(4)}, []string{"1.10\n1.9\n1.2\n"}, "1.2\n1.9\n1.10\n"},
+	}
+	for _, test := range tests {
+		s, err := New(test.opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var rs []io.Reader
+		for _, in := range test.in {
+			rs = append(rs, strings.NewReader(in))
+		}
+		var got bytes.Buffer
+		if err := s.Sort(&got, rs...); err != nil {
+			t.Fatal(err)
+		}
+		if got.String() != test.want {
+			t.Errorf("for %q expected %q, got %q", test.in, test.want, got.String())
+		}
+	}
+}
+
+func TestSorterOptionErrors(t *testing.T) {
+	for _, opt := range []Option{Key("x"), Mode("random"), Locale("!!"), Keep("middle"), Format("xml"), RecordStart("(")} {
+		if _, err := New(opt); err == nil {
+			t.Errorf("expected an error from %#v", opt)
+		}
+	}
+}
+
+func TestSorterOptionConflicts(t *testing.T) {
+	for _, opts := range [][]Option{
+		{Format("csv"), Unique()},
+		{Format("jsonl"), Count()},
+		{Format("json"), OnlyDuplicates()},
+		{Paragraphs(), Unique()},
+		{RecordStart("^#"), OnlySingles()},
+		{Format("tsv"), Mode("cidr"), CollapseCIDRs()},
+	} {
+		if _, err := New(opts...); err == nil {
+			t.Errorf("expected an error from %d options", len(opts))
+		}
+	}
+}
+
+func TestLocaleParseDoesNotPanic(t *testing.T) {
+	// Tags like this one crashed language.Parse before x/text v0.3.7.
+	for _, tag := range []string{"en-u-000-00", "en-u-xx-yy-"} {
+		New(Locale(tag)) //nolint:errcheck
+	}
+}
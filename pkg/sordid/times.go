@@ -0,0 +1,242 @@
+package sordid
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// timeLayouts are the layouts tried in turn when none is given. Times
+// without a zone are read in the key's time zone, and syslog stamps,
+// which carry no year, all fall in year zero.
+var timeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999 -0700",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02",
+	"02/Jan/2006:15:04:05 -0700", // Apache common log
+	time.Stamp,                   // syslog
+	time.RFC1123Z,
+	time.RFC1123,
+	time.UnixDate,
+	time.ANSIC,
+}
+
+// timeLayoutNames maps the names accepted as layouts onto Go layouts.
+var timeLayoutNames = map[string]string{
+	"rfc3339": time.RFC3339Nano,
+	"syslog":  time.Stamp,
+	"apache":  "02/Jan/2006:15:04:05 -0700",
+	"epoch":   epochLayout,
+}
+
+// epochLayout stands for seconds since 1970, or milli-, micro- or
+// nanoseconds when there are more than 12, 15 or 18 integer digits.
+const epochLayout = "epoch"
+
+// parseTimeLayout resolves a layout name, leaving Go layouts as they
+// are. An empty layout detects the format of each key.
+func parseTimeLayout(s string) (string, error) {
+	if l, ok := timeLayoutNames[strings.ToLower(s)]; ok {
+		return l, nil
+	}
+	if s != "" && !strings.ContainsAny(s, "0123456789") {
+		return "", errors.New("invalid time layout: " + s)
+	}
+	return s, nil
+}
+
+// timeCacheSize bounds the number of parsed keys a timeKey remembers.
+const timeCacheSize = 1 << 20
+
+// timeKey compares keys holding timestamps in modeTime.
+type timeKey struct {
+	layouts []string
+	loc     *time.Location
+	strict  bool
+	bad     *badTime
+
+	last  int // index of the layout that parsed the previous key
+	cache map[string]parsedTime
+}
+
+type parsedTime struct {
+	t  time.Time
+	ok bool
+}
+
+// badTime holds the first key a strict timeKey failed to parse. It is
+// shared with the timeKey's clones on other goroutines.
+type badTime struct {
+	mu  sync.Mutex
+	err error
+}
+
+// newTimeKey prepares k for comparing times, or returns nil outside
+// modeTime.
+func newTimeKey(k *keyOpts) *timeKey {
+	if k.mode != modeTime {
+		return nil
+	}
+	t := &timeKey{
+		layouts: timeLayouts,
+		loc:     k.timeZone,
+		strict:  k.strictTime,
+		bad:     &badTime{},
+		cache:   map[string]parsedTime{},
+	}
+	if k.timeLayout != "" {
+		t.layouts = []string{k.timeLayout}
+	}
+	if t.loc == nil {
+		t.loc = time.UTC
+	}
+	return t
+}
+
+// clone returns a timeKey for use on another goroutine that reports
+// unparseable keys along with t.
+func (t *timeKey) clone() *timeKey {
+	c := *t
+	c.cache = map[string]parsedTime{}
+	return &c
+}
+
+// err returns the error for the first key that failed to parse.
+func (t *timeKey) err() error {
+	t.bad.mu.Lock()
+	defer t.bad.mu.Unlock()
+	return t.bad.err
+}
+
+// compare orders two keys chronologically. Keys that are not times
+// sort after those that are, by their text, whichever the direction.
+func (t *timeKey) compare(a, b string, reverse bool) int {
+	ta, oka := t.parse(a)
+	tb, okb := t.parse(b)
+	switch {
+	case oka && okb:
+		if ta.Before(tb) {
+			return -1
+		}
+		if ta.After(tb) {
+			return 1
+		}
+		return 0
+	case oka != okb:
+		if reverse {
+			return compareBools(oka, okb)
+		}
+		return compareBools(okb, oka)
+	}
+	return strings.Compare(a, b)
+}
+
+// parse reads a key as a time, noting the key if it cannot.
+func (t *timeKey) parse(key string) (time.Time, bool) {
+	if p, ok := t.cache[key]; ok {
+		return p.t, p.ok
+	}
+	v, ok := t.parseLayouts(strings.Trim(key, " \t[]\"'"))
+	if !ok && t.strict {
+		t.bad.mu.Lock()
+		if t.bad.err == nil {
+			t.bad.err = errors.New("unparseable time: " + strconv.Quote(key))
+		}
+		t.bad.mu.Unlock()
+	}
+	if len(t.cache) >= timeCacheSize {
+		t.cache = map[string]parsedTime{}
+	}
+	t.cache[key] = parsedTime{v, ok}
+	return v, ok
+}
+
+// parseLayouts tries the layouts starting with the one that worked
+// last, as neighbouring keys tend to share a format.
+func (t *timeKey) parseLayouts(s string) (time.Time, bool) {
+	for i := range t.layouts {
+		n := (t.last + i) % len(t.layouts)
+		if v, ok := parseTime(t.layouts[n], s, t.loc); ok {
+			t.last = n
+			return v, true
+		}
+	}
+	if len(t.layouts) > 1 {
+		return parseTime(epochLayout, s, t.loc)
+	}
+	return time.Time{}, false
+}
+
+// parseTime parses s in a single layout.
+func parseTime(layout, s string, loc *time.Location) (time.Time, bool) {
+	if layout == epochLayout {
+		return parseEpoch(s)
+	}
+	v, err := time.ParseInLocation(layout, s, loc)
+	return v, err == nil
+}
+
+// parseEpoch reads a Unix timestamp such as "1600000000" or
+// "1600000000.25", taking longer integers as milli-, micro- or
+// nanoseconds.
+func parseEpoch(s string) (time.Time, bool) {
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		if skipDigits(s, i+1) != len(s) {
+			return time.Time{}, false
+		}
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return time.Time{}, false
+		}
+		sec, frac := math.Modf(f)
+		return time.Unix(int64(sec), int64(frac*1e9)).UTC(), true
+	}
+
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	switch digits := len(strings.TrimPrefix(s, "-")); {
+	case digits > 18:
+		return time.Unix(0, n).UTC(), true
+	case digits > 15:
+		return time.Unix(0, n*1e3).UTC(), true
+	case digits > 12:
+		return time.Unix(0, n*1e6).UTC(), true
+	}
+	return time.Unix(n, 0).UTC(), true
+}
+
+// err returns the error for the first unparseable time met by the
+// comparer's keys, if they are strict.
+func (c *comparer) err() error {
+	for i := range c.keys {
+		if t := c.keys[i].times; t != nil {
+			if err := t.err(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// timeErr is like comparer.err, also covering the record keys.
+func (o *options) timeErr() error {
+	if err := o.cmp.err(); err != nil || o.records == nil {
+		return err
+	}
+	for i := range o.records.keys {
+		if t := o.records.keys[i].times; t != nil {
+			if err := t.err(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
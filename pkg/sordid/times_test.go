@@ -0,0 +1,90 @@
+package sordid
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseEpoch(t *testing.T) {
+	tests := []struct {
+		in   string
+		want time.Time
+		ok   bool
+	}{
+		{"1600000000", time.Unix(1600000000, 0), true},
+		{"1600000000.5", time.Unix(1600000000, 5e8), true},
+		{"1600000000123", time.Unix(1600000000, 123e6), true},
+		{"1600000000123456", time.Unix(1600000000, 123456e3), true},
+		{"1600000000123456789", time.Unix(1600000000, 123456789), true},
+		{"-5", time.Unix(-5, 0), true},
+		{"16e8", time.Time{}, false},
+		{"2020-01-01", time.Time{}, false},
+		{"", time.Time{}, false},
+	}
+	for _, test := range tests {
+		got, ok := parseEpoch(test.in)
+		if ok != test.ok || !got.Equal(test.want) {
+			t.Errorf("parseEpoch(%q) = %v, %v, expected %v, %v", test.in, got, ok, test.want, test.ok)
+		}
+	}
+}
+
+func TestTimeMode(t *testing.T) {
+	tests := []struct {
+		opts []Option
+		in   string
+		want string
+	}{
+		// Mixed formats and zones sort by the instant they name.
+		{
+			[]Option{Mode("time"), Separator("|"), Key("1,1")},
+			"2020-01-01T12:00:00+02:00|b\n2020-01-01 10:30:00|c\n1577872800|a\n[01/Jan/2020:09:00:00 -0200]|d\n",
+			"2020-01-01T12:00:00+02:00|b\n1577872800|a\n2020-01-01 10:30:00|c\n[01/Jan/2020:09:00:00 -0200]|d\n",
+		},
+		{
+			[]Option{Mode("time"), TimeLayout("syslog"), Key("1,3")},
+			"Feb  1 00:00:00 x\nnot a time\nJan 31 23:59:59 y\nJan  2 10:00:00 z\n",
+			"Jan  2 10:00:00 z\nJan 31 23:59:59 y\nFeb  1 00:00:00 x\nnot a time\n",
+		},
+		// Unparseable keys stay last when reversed.
+		{
+			[]Option{Mode("time"), Reverse()},
+			"junk\n2020-01-02\n2020-01-01\n",
+			"2020-01-02\n2020-01-01\njunk\n",
+		},
+		{
+			[]Option{Mode("time"), TimeZone("America/New_York")},
+			"2020-01-01 06:00:00\n2020-01-01T10:00:00Z\n",
+			"2020-01-01T10:00:00Z\n2020-01-01 06:00:00\n",
+		},
+	}
+	for _, test := range tests {
+		s, err := New(test.opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got strings.Builder
+		if err := s.Sort(&got, strings.NewReader(test.in)); err != nil {
+			t.Fatal(err)
+		}
+		if got.String() != test.want {
+			t.Errorf("for %q expected %q, got %q", test.in, test.want, got.String())
+		}
+	}
+}
+
+func TestStrictTime(t *testing.T) {
+	s, err := New(Mode("time"), StrictTime())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out strings.Builder
+	err = s.Sort(&out, strings.NewReader("2020-01-02\nyesterday\n2020-01-01\n"))
+	if err == nil || !strings.Contains(err.Error(), `"yesterday"`) {
+		t.Errorf("expected an error naming the bad time, got %v", err)
+	}
+	if out.Len() != 0 {
+		t.Errorf("expected no output, got %q", out.String())
+	}
+}
@@ -0,0 +1,125 @@
+package sordid
+
+import (
+	"regexp"
+	"strings"
+)
+
+// semverPattern matches semantic versions, with an optional "v" prefix.
+var semverPattern = regexp.MustCompile(`^v?(\d+)\.(\d+)\.(\d+)(?:-([0-9A-Za-z.-]+))?(?:\+[0-9A-Za-z.-]+)?$`)
+
+// compareVersions orders two version strings. When both are semantic
+// versions the semver precedence rules apply, so "1.0.0-rc1" sorts
+// before "1.0.0"; anything else is compared the way dpkg does.
+func compareVersions(a, b string) int {
+	ma, mb := semverPattern.FindStringSubmatch(a), semverPattern.FindStringSubmatch(b)
+	if ma != nil && mb != nil {
+		return compareSemver(ma, mb)
+	}
+	return compareDebian(a, b)
+}
+
+// compareSemver compares two semverPattern matches.
+func compareSemver(a, b []string) int {
+	for i := 1; i <= 3; i++ {
+		if c := compareDigits(a[i], b[i]); c != 0 {
+			return c
+		}
+	}
+
+	// A release sorts after any of its pre-releases.
+	switch {
+	case a[4] == "" && b[4] == "":
+		return 0
+	case a[4] == "":
+		return 1
+	case b[4] == "":
+		return -1
+	}
+
+	pa, pb := strings.Split(a[4], "."), strings.Split(b[4], ".")
+	for i := 0; i < len(pa) && i < len(pb); i++ {
+		na, nb := isNumeric(pa[i]), isNumeric(pb[i])
+		var c int
+		switch {
+		case na && nb:
+			c = compareDigits(pa[i], pb[i])
+		case na != nb:
+			// Numeric identifiers have lower precedence.
+			c = compareBools(!na, !nb)
+		default:
+			c = strings.Compare(pa[i], pb[i])
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return compareInts(len(pa), len(pb))
+}
+
+func isNumeric(s string) bool {
+	return s != "" && skipDigits(s, 0) == len(s)
+}
+
+// compareDebian compares "[epoch:]upstream[-revision]" versions with
+// the dpkg algorithm, where "~" sorts before everything, even the end
+// of the string, so "1.0~rc1" comes before "1.0".
+func compareDebian(a, b string) int {
+	ea, ua, ra := splitDebian(a)
+	eb, ub, rb := splitDebian(b)
+	if c := compareDigits(ea, eb); c != 0 {
+		return c
+	}
+	if c := verrevcmp(ua, ub); c != 0 {
+		return c
+	}
+	return verrevcmp(ra, rb)
+}
+
+// splitDebian splits a version into its epoch, upstream version and
+// revision. A missing epoch is "0".
+func splitDebian(v string) (epoch, upstream, revision string) {
+	epoch = "0"
+	if i := strings.IndexByte(v, ':'); i > 0 && isNumeric(v[:i]) {
+		epoch, v = v[:i], v[i+1:]
+	}
+	if i := strings.LastIndexByte(v, '-'); i >= 0 {
+		return epoch, v[:i], v[i+1:]
+	}
+	return epoch, v, ""
+}
+
+// verrevcmp is the dpkg comparison of alternating non-digit and digit runs.
+func verrevcmp(a, b string) int {
+	for a != "" || b != "" {
+		for (a != "" && !isDigit(a[0])) || (b != "" && !isDigit(b[0])) {
+			oa, ob := debOrder(a), debOrder(b)
+			if oa != ob {
+				return compareInts(oa, ob)
+			}
+			a, b = a[1:], b[1:]
+		}
+
+		na, nb := skipDigits(a, 0), skipDigits(b, 0)
+		if c := compareDigits(strings.TrimLeft(a[:na], "0"), strings.TrimLeft(b[:nb], "0")); c != 0 {
+			return c
+		}
+		a, b = a[na:], b[nb:]
+	}
+	return 0
+}
+
+// debOrder weighs the first character of s for verrevcmp. Letters
+// sort before other symbols, and the end of the string or a digit
+// sorts between "~" and everything else.
+func debOrder(s string) int {
+	switch {
+	case s == "" || isDigit(s[0]):
+		return 0
+	case s[0] == '~':
+		return -1
+	case (s[0] >= 'a' && s[0] <= 'z') || (s[0] >= 'A' && s[0] <= 'Z'):
+		return int(s[0])
+	}
+	return int(s[0]) + 256
+}